@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	. "github.com/hajimehoshi/wasmserve/pkg"
+)
+
+// checkSizeBudgets checks the outputs built by res against the size budgets.
+// If fast is true, the brotli sizes are estimated with BrotliFast. The check
+// stops with the error of ctx when ctx is done.
+func checkSizeBudgets(ctx context.Context, res *buildResult, steps buildSteps, fast bool) ([]BudgetCheck, error) {
+	if len(Config.SizeBudgets) == 0 {
+		return nil, nil
+	}
+	outputs, err := buildOutputs(res, steps)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(outputs))
+	for name := range outputs {
+		names = append(names, name)
+	}
+	return CheckSizeBudgets(Config.SizeBudgets, names, func(name, encoding string) (int64, error) {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		data, err := os.ReadFile(outputs[name])
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case encoding == BudgetGzip:
+			data, err = Gzip(data)
+		case encoding == BudgetBrotli && fast:
+			data, err = BrotliFast(data)
+		case encoding == BudgetBrotli:
+			data, err = Brotli(data)
+		}
+		return int64(len(data)), err
+	})
+}
+
+// printSizeBudgets prints a table of the checks.
+func printSizeBudgets(out io.Writer, checks []BudgetCheck) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "OUTPUT\tSIZE\tBUDGET\t")
+	for _, c := range checks {
+		status := "ok"
+		if c.Over() {
+			status = "OVER by " + FormatSize(c.Size-c.Limit)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", c.Name(), FormatSize(c.Size), FormatSize(c.Limit), status)
+	}
+	w.Flush()
+}
+
+// enforceSizeBudgets prints the checks of the outputs of res, and returns an
+// error if some are over their budgets.
+func enforceSizeBudgets(res *buildResult, steps buildSteps) error {
+	checks, err := checkSizeBudgets(context.Background(), res, steps, false)
+	if err != nil {
+		return fmt.Errorf("checking the size budgets: %v", err)
+	}
+	if len(checks) == 0 {
+		return nil
+	}
+	printSizeBudgets(os.Stderr, checks)
+	var over []string
+	for _, c := range checks {
+		if c.Over() {
+			over = append(over, c.String())
+		}
+	}
+	if len(over) > 0 {
+		return fmt.Errorf("over the size budget: %s", strings.Join(over, "; "))
+	}
+	return nil
+}
+
+// warnSizeBudgets logs the outputs of res that are over their budgets. The
+// brotli sizes are estimated with BrotliFast, and the estimates are marked.
+func warnSizeBudgets(ctx context.Context, res *buildResult, steps buildSteps) {
+	checks, err := checkSizeBudgets(ctx, res, steps, true)
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	if err != nil {
+		log.Printf("Checking the size budgets: %v", err)
+		return
+	}
+	for _, c := range checks {
+		if c.Over() {
+			if c.Encoding == BudgetBrotli {
+				log.Printf("Warning: %s (estimated at brotli quality %d)", c, BrotliFastQuality)
+				continue
+			}
+			log.Printf("Warning: %s", c)
+		}
+	}
+}
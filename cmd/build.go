@@ -15,12 +15,17 @@
 package cmd
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
 	. "github.com/hajimehoshi/wasmserve/pkg"
 	"github.com/spf13/cobra"
@@ -51,43 +56,59 @@ func removeIfContains(e []string, dir string) []string {
 	return e
 }
 
-func buildTailwindCss(cssPath string) (*CssPath, error) {
-	output := Config.TmpDir
-
-	rf := strings.Split(cssPath, "/")
-	filename := rf[len(rf)-1]
-
-	workdir := "."
-	outpath := filepath.Join(output, filename)
-	args := []string{"-i", cssPath, "-o", outpath}
-
-	var ex string
-	// if the user is using npx tailwindcss or something like that we need to seperate the starting point and the rest
-	if rest := strings.Split(Config.TailwindExec, " "); len(rest) > 1 {
-		ex = rest[0]
-		log.Println(rest)
-		rest = rest[1:]
-		args = append(rest, args...)
-	} else {
-		ex = Config.TailwindExec
+// tempPath creates an empty file next to path, for a command to write an
+// artifact to before it is renamed to path. That way the server never serves a
+// partially written artifact.
+func tempPath(path string) (string, error) {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
 	}
-
-	cmdBuild := exec.Command(ex, args...)
-	cmdBuild.Dir = workdir
-	out, err := cmdBuild.CombinedOutput()
+	f, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*")
 	if err != nil {
-		log.Print(err)
-		log.Print(string(out))
-		return nil, err
+		return "", err
+	}
+	// CreateTemp creates files only readable by the owner.
+	if err := f.Chmod(0644); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
 	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
 
-	return &CssPath{Output: outpath, Input: cssPath}, nil
+// cssOutputPath returns the path of the css built from cssPath, with the
+// extension of css.
+func cssOutputPath(cssPath string) string {
+	return tmpOutputPath(CssOutputName(cssPath))
+}
+
+// tmpOutputPath returns the path in Config.TmpDir of the output name. The
+// outputs have the same directory structure as the inputs, so that inputs with
+// the same name don't overwrite each other.
+func tmpOutputPath(name string) string {
+	rel := filepath.Clean(name)
+	if filepath.IsAbs(rel) || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		rel = filepath.Base(rel)
+	}
+	return filepath.Join(Config.TmpDir, rel)
 }
 
 func cssFilesFromDir(rd string) []string {
 	var excludeDirs excludableDirs = Config.Build.ExcludeDir
 	// Remove if
 	excludeDirs = removeIfContains(excludeDirs, rd)
+	// The outputs in the tmp dir and the unwatched files, such as node_modules,
+	// are not inputs. The tmp dir itself is scanned for outputs though.
+	var excludeGlobs []string
+	outputs := filepath.Clean(rd) == filepath.Clean(Config.TmpDir)
+	if !outputs {
+		excludeGlobs = Config.WatchExclude()
+	}
 	var compilable []string
 	err := filepath.Walk(rd, func(path string, info os.FileInfo, err error) error {
 		// path is absolute
@@ -98,12 +119,24 @@ func cssFilesFromDir(rd string) []string {
 		if excludeDirs.Contains(info.Name()) {
 			return filepath.SkipDir
 		}
+		for _, g := range excludeGlobs {
+			if MatchGlob(g, filepath.ToSlash(path)) {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
 
 		if info.IsDir() {
+			// The processors write to hidden directories in the tmp dir.
+			if outputs && path != rd && strings.HasPrefix(info.Name(), ".") {
+				return filepath.SkipDir
+			}
 			return nil
 		}
 
-		if strings.HasSuffix(path, ".css") {
+		if outputs && filepath.Ext(path) == ".css" || !outputs && IsStylesheet(path) && !IsSassPartial(path) {
 			compilable = append(compilable, path)
 		}
 
@@ -117,25 +150,136 @@ func cssFilesFromDir(rd string) []string {
 	return compilable
 }
 
-func buildAllCssFiles() {
-	var compilable = cssFilesFromDir(".")
+// cssInputs returns the stylesheets to build: the ones matching css_entries,
+// or every stylesheet except the Sass partials if css_entries is empty.
+func cssInputs() []string {
+	files := cssFilesFromDir(".")
+	if len(Config.CssEntries) == 0 {
+		return files
+	}
+	var entries []string
+	for _, f := range files {
+		for _, g := range Config.CssEntries {
+			if MatchGlob(filepath.ToSlash(filepath.Clean(g)), filepath.ToSlash(f)) {
+				entries = append(entries, f)
+				break
+			}
+		}
+	}
+	return entries
+}
+
+// usesTailwind reports whether the css file cssPath needs to be built by
+// Tailwind.
+func usesTailwind(cssPath string) (bool, error) {
+	src, err := os.ReadFile(cssPath)
+	if err != nil {
+		return false, err
+	}
+	return UsesTailwind(src), nil
+}
+
+// tailwindInputs returns the inputs that are built by Tailwind alone.
+func tailwindInputs(inputs []string) []string {
+	var tw []string
+	for _, f := range inputs {
+		if chain, err := cssChain(f); err != nil {
+			log.Print(err)
+		} else if len(chain) == 1 && chain[0] == CssProcessorTailwind {
+			tw = append(tw, f)
+		}
+	}
+	return tw
+}
+
+// tailwindChainInputs returns the inputs that Tailwind builds, alone or with
+// other processors. Tailwind scans the Go files for classes, so they are the
+// stylesheets that depend on the Go files.
+func tailwindChainInputs(inputs []string) []string {
+	var tw []string
+	for _, f := range inputs {
+		chain, err := cssChain(f)
+		if err != nil {
+			log.Print(err)
+			continue
+		}
+		for _, name := range chain {
+			if name == CssProcessorTailwind {
+				tw = append(tw, f)
+				break
+			}
+		}
+	}
+	return tw
+}
+
+// cssBuild is the result of building one css file.
+type cssBuild struct {
+	Input    string
+	Path     *CssPath
+	Duration time.Duration
+	Err      error
+}
+
+// buildAllCssFiles builds the given css files, or every css input if inputs is
+// empty. At most Config.TailwindWorkerCount()
+// files are built at the same time. When ctx is done, the files that were not
+// started fail with the error of ctx.
+func buildAllCssFiles(ctx context.Context, inputs []string) []cssBuild {
+	var compilable = inputs
+	if len(compilable) == 0 {
+		compilable = cssInputs()
+	}
+	results := make([]cssBuild, len(compilable))
+	jobs := make(chan int)
 	var wg sync.WaitGroup
 
-	for _, f := range compilable {
+	for i := 0; i < Config.TailwindWorkerCount() && i < len(compilable); i++ {
 		wg.Add(1)
-
-		go func(file string) {
+		go func() {
 			defer wg.Done()
-			cssPath, err := buildTailwindCss(file)
-			if err == nil {
-				cssFiles.Add(cssPath)
-			} else {
-				log.Print(err.Error())
+			for j := range jobs {
+				start := time.Now()
+				cssPath, err := buildCssFile(ctx, compilable[j])
+				results[j] = cssBuild{Input: compilable[j], Path: cssPath, Duration: time.Since(start), Err: err}
+				if err == nil {
+					cssFiles.Add(cssPath)
+					log.Printf("Built %s in %s", compilable[j], results[j].Duration)
+				} else if ctx.Err() == nil {
+					log.Print(err.Error())
+				}
 			}
-		}(f)
+		}()
 	}
 
+	started := 0
+feed:
+	for ; started < len(compilable); started++ {
+		select {
+		case jobs <- started:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
 	wg.Wait()
+
+	for i := started; i < len(compilable); i++ {
+		results[i] = cssBuild{Input: compilable[i], Err: ctx.Err()}
+	}
+	return results
+}
+
+// removeCssOutput forgets the css file built from the removed input cssPath,
+// and removes its output and source map.
+func removeCssOutput(cssPath string) {
+	p := &CssPath{Input: cssPath, Output: cssOutputPath(cssPath)}
+	cssFiles.Remove(p.URLPath())
+	for _, f := range []string{p.Output, p.Output + ".map"} {
+		if err := os.Remove(f); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Print(err)
+		}
+	}
 }
 
 func initCssFiles() {
@@ -155,45 +299,269 @@ func hasGo111Module(env []string) bool {
 	return false
 }
 
-func buildWasm() {
-	// go build
-	args := []string{"build", "-o", Config.WasmPath}
-	// move flags to conf
-	// if *flagTags != "" {
-	// 	args = append(args, "-tags", *flagTags)
-	// }
-	// if *flagOverlay != "" {
-	// 	args = append(args, "-overlay", *flagOverlay)
-	// }
-	// if len(flag.Args()) > 0 {
-	// 	args = append(args, flag.Args()[0])
-	// } else {
-	// 	args = append(args, ".")
-	// }
-	args = append(args, ".")
-
-	cmdBuild := exec.Command("go", args...)
-	cmdBuild.Env = append(os.Environ(), "GOOS=js", "GOARCH=wasm")
+// wasmEnv returns the environment of the go commands that build the wasm.
+func wasmEnv() []string {
+	env := append(os.Environ(), "GOOS=js", "GOARCH=wasm")
 	// If GO111MODULE is not specified explicitly, enable Go modules.
 	// Enabling this is for backward compatibility of wasmserve.
-	if !hasGo111Module(cmdBuild.Env) {
-		cmdBuild.Env = append(cmdBuild.Env, "GO111MODULE=on")
+	if !hasGo111Module(env) {
+		env = append(env, "GO111MODULE=on")
+	}
+	return env
+}
+
+// goBuildFlags returns the flags of go build that also change what go list
+// reports.
+func goBuildFlags() []string {
+	var args []string
+	if Config.Tags != "" {
+		args = append(args, "-tags", Config.Tags)
 	}
+	if Config.Overlay != "" {
+		args = append(args, "-overlay", Config.Overlay)
+	}
+	return args
+}
+
+func buildWasm(ctx context.Context) error {
+	tmppath, err := tempPath(Config.WasmPath)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmppath)
+	abs, err := filepath.Abs(tmppath)
+	if err != nil {
+		return err
+	}
+
+	wasmOpt := wasmOptCommand()
+	cache, err := wasmCache()
+	if err != nil {
+		log.Print(err)
+	}
+	var key string
+	if cache != nil {
+		if key, err = wasmCacheKey(wasmOpt); err != nil {
+			log.Printf("Not using the build cache: %v", err)
+		} else if ok, err := cache.Get(key, tmppath); err != nil {
+			log.Print(err)
+		} else if ok {
+			log.Printf("Using the cached build of %s", Config.WasmPath)
+			return os.Rename(tmppath, Config.WasmPath)
+		}
+	}
+
+	// go build
+	args := []string{"build", "-o", abs}
+	args = append(args, wasmBuildFlags()...)
+	args = append(args, ".")
+
+	cmdBuild := exec.CommandContext(ctx, "go", args...)
+	cmdBuild.Env = wasmEnv()
 	cmdBuild.Dir = Config.Root
 	out, err := cmdBuild.CombinedOutput()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
 	if err != nil {
-		log.Print(err)
-		log.Print(string(out))
-		return
+		return fmt.Errorf("go build: %v\n%s", err, out)
 	}
 	if len(out) > 0 {
 		log.Print(string(out))
 	}
+	if wasmOpt != nil {
+		if err := runWasmOpt(ctx, wasmOpt, tmppath); err != nil {
+			return err
+		}
+	}
+	// Don't cache the output if the inputs changed during the build.
+	if key != "" {
+		if k, err := wasmCacheKey(wasmOpt); err == nil && k == key {
+			if err := cache.Put(key, tmppath); err != nil {
+				log.Print(err)
+			}
+		}
+	}
+	return os.Rename(tmppath, Config.WasmPath)
+}
+
+// buildResult describes a finished build.
+type buildResult struct {
+	Start    time.Time
+	Duration time.Duration
+	CssPaths []*CssPath
+	// CssBuilds holds the result of every css file, including failed ones.
+	CssBuilds []cssBuild
+	// JsOutputs are the bundles of the [js] entries and their source maps.
+	JsOutputs []string
+	// Err is the first error of the build, or nil if the build succeeded.
+	Err error
+}
+
+// buildOutputs maps the URL paths of the wasm, css and js files built by res
+// to the files in tmp_dir.
+func buildOutputs(res *buildResult, steps buildSteps) (map[string]string, error) {
+	outputs := map[string]string{}
+	if steps.Wasm {
+		outputs[Config.WasmFile] = Config.WasmPath
+	}
+	for _, p := range res.CssPaths {
+		outputs[strings.TrimPrefix(p.URLPath(), "/")] = p.Output
+	}
+	for _, f := range res.JsOutputs {
+		if filepath.Ext(f) != ".js" {
+			continue
+		}
+		rel, err := filepath.Rel(Config.TmpDir, f)
+		if err != nil {
+			return nil, err
+		}
+		outputs[filepath.ToSlash(rel)] = f
+	}
+	return outputs, nil
+}
+
+// buildSteps selects the parts of a build to run.
+type buildSteps struct {
+	Wasm bool
+	Css  bool
+	Js   bool
+	// CssInputs limits the css step to these files. If it is empty, every css
+	// file is built.
+	CssInputs []string
+	// Skipped explains why parts of the build are not run.
+	Skipped []string
+}
+
+var allSteps = buildSteps{Wasm: true, Css: true, Js: true}
+
+// empty reports whether there is nothing to build.
+func (s buildSteps) empty() bool {
+	return !s.Wasm && !s.Css && !s.Js
+}
+
+// merge returns the steps that build everything s and t build.
+func (s buildSteps) merge(t buildSteps) buildSteps {
+	m := buildSteps{Wasm: s.Wasm || t.Wasm, Css: s.Css || t.Css, Js: s.Js || t.Js}
+	// Empty inputs mean every css file.
+	if (s.Css && len(s.CssInputs) == 0) || (t.Css && len(t.CssInputs) == 0) {
+		return m
+	}
+	seen := map[string]bool{}
+	for _, f := range append(append([]string{}, s.CssInputs...), t.CssInputs...) {
+		if !seen[f] {
+			seen[f] = true
+			m.CssInputs = append(m.CssInputs, f)
+		}
+	}
+	return m
+}
+
+// buildError turns the error of a cancelled build into a readable one.
+func buildError(ctx context.Context, err error) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("build timed out after %s", Config.BuildTimeoutDuration())
+	}
+	return err
+}
+
+// build runs the pre_build hooks, runs the selected build steps and runs the
+// post_build hooks. The commands are killed when ctx is done, in which case
+// the post_build hooks are not run.
+func build(ctx context.Context, steps buildSteps) *buildResult {
+	res := &buildResult{Start: time.Now()}
+	if err := runHooks(ctx, HookPreBuild, Config.PreBuild, nil); err != nil {
+		res.Err = buildError(ctx, err)
+		res.Duration = time.Since(res.Start)
+		return res
+	}
+
+	if (steps.Css || steps.Wasm) && Config.EnableTailwind {
+		// Go files changed, so the classes might have too.
+		if err := writeTailwindClasses(); err != nil {
+			log.Printf("Scanning the Go files for classes: %v", err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	var cssErr, wasmErr, jsErr error
+	if steps.Css && Config.CssEnabled() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res.CssBuilds = buildAllCssFiles(ctx, steps.CssInputs)
+			for _, b := range res.CssBuilds {
+				if b.Err != nil {
+					if cssErr == nil {
+						cssErr = b.Err
+					}
+					continue
+				}
+				res.CssPaths = append(res.CssPaths, b.Path)
+			}
+		}()
+	}
+	if steps.Wasm {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			wasmErr = buildWasm(ctx)
+		}()
+	}
+	if steps.Js && Config.JsEnabled() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res.JsOutputs, jsErr = buildJs(ctx)
+		}()
+	}
+
+	wg.Wait()
+	if wasmErr != nil {
+		res.Err = wasmErr
+	} else if cssErr != nil {
+		res.Err = cssErr
+	} else if jsErr != nil {
+		res.Err = jsErr
+	}
+	if res.Err == nil && ctx.Err() == nil && Config.HashFilenames {
+		if err := hashOutputs(res, steps); err != nil {
+			res.Err = fmt.Errorf("hashing the outputs: %v", err)
+		}
+	}
+	res.Duration = time.Since(res.Start)
+	if ctx.Err() != nil {
+		res.Err = buildError(ctx, ctx.Err())
+		return res
+	}
+
+	if err := runHooks(ctx, HookPostBuild, Config.PostBuild, res); err != nil && res.Err == nil {
+		res.Err = err
+	}
+	return res
+}
+
+// runBuild runs a build of steps that is killed after build_timeout or when
+// the process is interrupted.
+func runBuild(steps buildSteps) *buildResult {
+	ctx, cancel := context.WithTimeout(context.Background(), Config.BuildTimeoutDuration())
+	defer cancel()
+	signalChan := make(chan os.Signal, 1)
+	signal.Notify(signalChan, os.Interrupt)
+	defer signal.Stop(signalChan)
+	go func() {
+		select {
+		case <-signalChan:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return build(ctx, steps)
 }
 
 var buildCmd = &cobra.Command{
 	Use:              "build",
-	Short:            "build all the webassembly and css files",
+	Short:            "build all the webassembly, css and js files",
 	Long:             `TODO`,
 	TraverseChildren: true,
 	Run: func(cmd *cobra.Command, args []string) {
@@ -202,21 +570,22 @@ var buildCmd = &cobra.Command{
 			log.Fatal(err)
 			return
 		}
-
-		var wg sync.WaitGroup
-		if Config.EnableTailwind {
-			wg.Add(1)
-			go func() {
-				defer wg.Done()
-				buildAllCssFiles()
-			}()
+		if err := initProfile(flagProfile); err != nil {
+			log.Fatal(err)
+			return
+		}
+		if err := initTailwind(); err != nil {
+			log.Fatal(err)
+			return
 		}
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			buildWasm()
-		}()
 
-		wg.Wait()
+		logJsMinifySizes = true
+		res := runBuild(allSteps)
+		if res.Err != nil {
+			log.Fatal(res.Err)
+		}
+		if err := enforceSizeBudgets(res, allSteps); err != nil {
+			log.Fatal(err)
+		}
 	},
 }
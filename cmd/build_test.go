@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildStepsMerge(t *testing.T) {
+	cases := []struct {
+		name string
+		s, t buildSteps
+		want buildSteps
+	}{
+		{
+			name: "empty",
+			want: buildSteps{},
+		},
+		{
+			name: "different steps",
+			s:    buildSteps{Wasm: true},
+			t:    buildSteps{Js: true},
+			want: buildSteps{Wasm: true, Js: true},
+		},
+		{
+			name: "css inputs",
+			s:    buildSteps{Css: true, CssInputs: []string{"a.css"}},
+			t:    buildSteps{Css: true, CssInputs: []string{"b.css", "a.css"}},
+			want: buildSteps{Css: true, CssInputs: []string{"a.css", "b.css"}},
+		},
+		{
+			name: "every css file first",
+			s:    buildSteps{Css: true},
+			t:    buildSteps{Css: true, CssInputs: []string{"a.css"}},
+			want: buildSteps{Css: true},
+		},
+		{
+			name: "every css file last",
+			s:    buildSteps{Wasm: true, Css: true, CssInputs: []string{"a.css"}},
+			t:    buildSteps{Css: true},
+			want: buildSteps{Wasm: true, Css: true},
+		},
+		{
+			name: "no css step",
+			s:    buildSteps{Wasm: true},
+			t:    buildSteps{Css: true, CssInputs: []string{"a.css"}},
+			want: buildSteps{Wasm: true, Css: true, CssInputs: []string{"a.css"}},
+		},
+		{
+			name: "skipped messages are dropped",
+			s:    buildSteps{Js: true, Skipped: []string{"Skipping wasm"}},
+			t:    buildSteps{Skipped: []string{"Skipping build"}},
+			want: buildSteps{Js: true},
+		},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.want, c.s.merge(c.t), c.name)
+	}
+}
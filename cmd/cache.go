@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	. "github.com/hajimehoshi/wasmserve/pkg"
+	"github.com/spf13/cobra"
+)
+
+// cacheEnv lists the environment variables that change the output of go build,
+// besides GOOS and GOARCH.
+var cacheEnv = []string{"GO111MODULE", "GOFLAGS", "GOEXPERIMENT", "GOWASM", "GOTOOLCHAIN", "GOPATH", "GOROOT"}
+
+func hashFile(h io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fh := sha256.New()
+	if _, err := io.Copy(fh, f); err != nil {
+		return err
+	}
+	fmt.Fprintf(h, "file %s %x\n", path, fh.Sum(nil))
+	return nil
+}
+
+// wasmCacheKey returns the hash of the inputs of the wasm build: the local
+// source files from go list, the module files, the flags, the environment and
+// the Go version, and the wasm-opt command the output is optimized with.
+// Dependencies in the module cache are identified by go.sum.
+func wasmCacheKey(wasmOpt []string) (string, error) {
+	if Config.Overlay != "" {
+		return "", errors.New("the build cache does not support overlay")
+	}
+
+	out, err := exec.Command("go", "env", "GOVERSION").Output()
+	if err != nil {
+		return "", fmt.Errorf("go env GOVERSION: %v", err)
+	}
+	g, err := loadBuildGraph()
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "go %s\n", strings.TrimSpace(string(out)))
+	fmt.Fprintf(h, "flags %q\n", wasmBuildFlags())
+	if wasmOpt != nil {
+		fmt.Fprintf(h, "wasm-opt %q\n", wasmOpt)
+	}
+	for _, e := range cacheEnv {
+		fmt.Fprintf(h, "env %s=%s\n", e, os.Getenv(e))
+	}
+
+	files := make([]string, 0, len(g.files))
+	for f := range g.files {
+		files = append(files, f)
+	}
+	if vendor, err := filepath.Abs(filepath.Join(Config.Root, "vendor", "modules.txt")); err == nil {
+		files = append(files, vendor)
+	}
+	sort.Strings(files)
+	for _, f := range files {
+		if err := hashFile(h, f); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)) + ".wasm", nil
+}
+
+func wasmCache() (*Cache, error) {
+	if Config.Cache.Disable {
+		return nil, nil
+	}
+	return Config.WasmCache()
+}
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the cache of built webassembly files",
+	Long:  `TODO`,
+}
+
+var cacheCleanCmd = &cobra.Command{
+	Use:   "clean",
+	Short: "Remove every cached webassembly file",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := initConf(); err != nil {
+			log.Fatal(err)
+			return
+		}
+		c, err := Config.WasmCache()
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+		s, err := c.Stats()
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+		if err := c.Clean(); err != nil {
+			log.Fatal(err)
+			return
+		}
+		fmt.Printf("Removed %d cached builds (%s) from %s\n", s.Entries, FormatSize(s.Size), c.Dir)
+	},
+}
+
+var cacheStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show the size of the cache",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := initConf(); err != nil {
+			log.Fatal(err)
+			return
+		}
+		c, err := Config.WasmCache()
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+		s, err := c.Stats()
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+		fmt.Printf("Directory: %s\n", c.Dir)
+		fmt.Printf("Entries:   %d\n", s.Entries)
+		fmt.Printf("Size:      %s of %s\n", FormatSize(s.Size), FormatSize(c.MaxSize))
+	},
+}
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	. "github.com/hajimehoshi/wasmserve/pkg"
+)
+
+// isGoModFile reports whether name is a Go module or workspace file.
+func isGoModFile(name string) bool {
+	switch filepath.Base(name) {
+	case "go.mod", "go.sum", "go.work", "go.work.sum":
+		return true
+	}
+	return false
+}
+
+// stepsForChanges returns the build steps affected by the changed files:
+//
+//   - Go module files need the wasm.
+//   - Go files need the wasm and, since Tailwind scans them for classes, the
+//     stylesheets built by Tailwind.
+//   - A stylesheet needs only itself, or every stylesheet if it is a partial.
+//   - A JavaScript or TypeScript file needs the [js] bundles.
+//   - Other files, such as templates, need no build. The browsers only reload.
+//
+// A removed directory might have contained anything, so it needs everything.
+func stepsForChanges(files []string) buildSteps {
+	var steps buildSteps
+	var goFiles, modFiles, cssChanged, jsChanged, others []string
+	for _, f := range files {
+		switch {
+		case strings.HasSuffix(f, string(filepath.Separator)):
+			return allSteps
+		case isGoModFile(f):
+			modFiles = append(modFiles, f)
+		case filepath.Ext(f) == ".go":
+			goFiles = append(goFiles, f)
+		case IsStylesheet(f):
+			cssChanged = append(cssChanged, f)
+		case IsJsFile(f) && Config.JsEnabled():
+			jsChanged = append(jsChanged, f)
+		default:
+			others = append(others, f)
+		}
+	}
+
+	steps.Wasm = len(goFiles) > 0 || len(modFiles) > 0
+	steps.Js = len(jsChanged) > 0
+	if len(cssChanged) > 0 {
+		steps.Css = true
+		inputs, partials := affectedCssInputs(cssChanged)
+		if len(partials) > 0 {
+			// Any entry might import the partials.
+			steps.Skipped = append(steps.Skipped, fmt.Sprintf("Building every css entry: %s is not an entry and may be imported", strings.Join(partials, ", ")))
+		} else {
+			steps.CssInputs = inputs
+		}
+	}
+	if len(goFiles) > 0 {
+		if tw := tailwindChainInputs(cssInputs()); len(tw) > 0 {
+			m := steps.merge(buildSteps{Css: true, CssInputs: tw})
+			steps.Css, steps.CssInputs = m.Css, m.CssInputs
+		}
+	}
+
+	if steps.Css && !Config.CssEnabled() {
+		steps.Css = false
+		steps.CssInputs = nil
+		steps.Skipped = append(steps.Skipped, "Skipping css: neither tailwind, the native css pipeline nor the css processors are enabled")
+	}
+	if !steps.Wasm && len(cssChanged) > 0 && !steps.Js {
+		steps.Skipped = append(steps.Skipped, "Skipping wasm: only stylesheets changed")
+	}
+	if !steps.Wasm && steps.Js {
+		steps.Skipped = append(steps.Skipped, fmt.Sprintf("Bundling only the js entries: %s changed", strings.Join(jsChanged, ", ")))
+	}
+	if steps.Css && len(steps.CssInputs) > 0 && len(goFiles) == 0 {
+		steps.Skipped = append(steps.Skipped, fmt.Sprintf("Building only %s: no Go files changed", strings.Join(steps.CssInputs, ", ")))
+	}
+	if steps.empty() && len(others) > 0 {
+		steps.Skipped = append(steps.Skipped, fmt.Sprintf("Skipping build: %s only needs a reload", strings.Join(others, ", ")))
+	}
+	return steps
+}
+
+// affectedCssInputs splits the changed stylesheets into the inputs and the
+// other files, which are partials imported by the inputs.
+func affectedCssInputs(changed []string) (inputs, partials []string) {
+	all := map[string]bool{}
+	for _, f := range cssInputs() {
+		all[filepath.Clean(f)] = true
+	}
+	for _, f := range changed {
+		if all[filepath.Clean(f)] {
+			inputs = append(inputs, f)
+		} else {
+			partials = append(partials, f)
+		}
+	}
+	return inputs, partials
+}
@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/hajimehoshi/wasmserve/pkg"
+	"github.com/stretchr/testify/assert"
+)
+
+// useTestProject makes a temporary project with the css files files, makes it
+// the working directory, and resets Config to the defaults with the native
+// css pipeline, the css entries entries and a js entry. It returns a function
+// that restores both.
+func useTestProject(t *testing.T, files, entries []string) func() {
+	dir := t.TempDir()
+	for _, f := range files {
+		p := filepath.Join(dir, filepath.FromSlash(f))
+		assert.Nil(t, os.MkdirAll(filepath.Dir(p), 0755))
+		assert.Nil(t, os.WriteFile(p, []byte("body { margin: 0; }"), 0644))
+	}
+	wd, err := os.Getwd()
+	assert.Nil(t, err)
+	assert.Nil(t, os.Chdir(dir))
+
+	old := *Config
+	*Config = DefaultConfig()
+	Config.CssPipeline = CssPipelineNative
+	Config.CssEntries = entries
+	Config.Js.Entries = []string{"js/app.ts"}
+	return func() {
+		*Config = old
+		os.Chdir(wd)
+	}
+}
+
+func TestStepsForChanges(t *testing.T) {
+	defer useTestProject(t, []string{"css/main.css", "css/theme.css", "css/parts/buttons.css"}, []string{"css/main.css", "css/theme.css"})()
+	// theme.css is built by Tailwind, and main.css by the native pipeline.
+	Config.EnableTailwind = true
+	assert.Nil(t, os.WriteFile(filepath.Join("css", "theme.css"), []byte("@tailwind utilities;"), 0644))
+
+	cases := []struct {
+		name    string
+		files   []string
+		want    buildSteps
+		skipped string
+	}{
+		{
+			name:  "go file",
+			files: []string{"main.go"},
+			want:  buildSteps{Wasm: true, Css: true, CssInputs: []string{"css/theme.css"}},
+		},
+		{
+			name:  "module file",
+			files: []string{"go.sum"},
+			want:  buildSteps{Wasm: true},
+		},
+		{
+			name:    "css entry",
+			files:   []string{"css/main.css"},
+			want:    buildSteps{Css: true, CssInputs: []string{"css/main.css"}},
+			skipped: "Skipping wasm: only stylesheets changed",
+		},
+		{
+			name:  "css entries",
+			files: []string{"css/main.css", "css/theme.css"},
+			want:  buildSteps{Css: true, CssInputs: []string{"css/main.css", "css/theme.css"}},
+		},
+		{
+			name:    "partial",
+			files:   []string{"css/main.css", "css/parts/buttons.css"},
+			want:    buildSteps{Css: true},
+			skipped: "Building every css entry: css/parts/buttons.css is not an entry",
+		},
+		{
+			name:  "go and css files",
+			files: []string{"css/main.css", "main.go"},
+			want:  buildSteps{Wasm: true, Css: true, CssInputs: []string{"css/main.css", "css/theme.css"}},
+		},
+		{
+			name:  "go file and partial",
+			files: []string{"css/parts/buttons.css", "main.go"},
+			want:  buildSteps{Wasm: true, Css: true},
+		},
+		{
+			name:    "js file",
+			files:   []string{"js/util.ts"},
+			want:    buildSteps{Js: true},
+			skipped: "Bundling only the js entries: js/util.ts changed",
+		},
+		{
+			name:  "css and js files",
+			files: []string{"css/theme.css", "js/app.ts"},
+			want:  buildSteps{Css: true, Js: true, CssInputs: []string{"css/theme.css"}},
+		},
+		{
+			name:    "template",
+			files:   []string{"index.html"},
+			want:    buildSteps{},
+			skipped: "Skipping build: index.html only needs a reload",
+		},
+		{
+			name:  "removed directory",
+			files: []string{"index.html", "css" + string(filepath.Separator)},
+			want:  allSteps,
+		},
+	}
+	for _, c := range cases {
+		got := stepsForChanges(c.files)
+		skipped := got.Skipped
+		got.Skipped = nil
+		assert.Equal(t, c.want, got, c.name)
+		if c.skipped != "" {
+			assert.Contains(t, strings.Join(skipped, "\n"), c.skipped, c.name)
+		}
+	}
+
+	// Without Tailwind, no stylesheet depends on the Go files.
+	Config.EnableTailwind = false
+	got := stepsForChanges([]string{"main.go"})
+	assert.Equal(t, buildSteps{Wasm: true}, got)
+
+	// Without css processing, the stylesheets need no build.
+	Config.CssPipeline = ""
+	got = stepsForChanges([]string{"css/main.css"})
+	assert.True(t, got.empty())
+	assert.Contains(t, strings.Join(got.Skipped, "\n"), "Skipping css")
+
+	// Without [js] entries, the js files need no build.
+	Config.Js.Entries = nil
+	got = stepsForChanges([]string{"js/app.ts"})
+	assert.True(t, got.empty())
+	assert.Contains(t, strings.Join(got.Skipped, "\n"), "only needs a reload")
+}
@@ -0,0 +1,279 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+
+	. "github.com/hajimehoshi/wasmserve/pkg"
+	"github.com/spf13/cobra"
+)
+
+// devBuilder runs one build at a time. Requesting a build while another one
+// is running cancels the running one, and its steps are built again together
+// with the requested ones.
+type devBuilder struct {
+	mu      sync.Mutex
+	pending buildSteps
+	running buildSteps
+	cancel  context.CancelFunc
+	wake    chan struct{}
+	// cancelBudgets cancels the size budget check of the last build.
+	cancelBudgets context.CancelFunc
+}
+
+func newDevBuilder() *devBuilder {
+	return &devBuilder{wake: make(chan struct{}, 1)}
+}
+
+// request schedules the steps to be built.
+func (b *devBuilder) request(steps buildSteps) {
+	for _, s := range steps.Skipped {
+		log.Print(s)
+	}
+	if steps.empty() {
+		events.publish(eventReload, "")
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.pending = b.pending.merge(steps)
+	if b.cancel != nil {
+		log.Print("Cancelling the running build for newer changes")
+		b.pending = b.pending.merge(b.running)
+		b.cancel()
+		b.cancel = nil
+	}
+	select {
+	case b.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run builds the requested steps until ctx is done.
+func (b *devBuilder) run(ctx context.Context) {
+	for {
+		select {
+		case <-b.wake:
+		case <-ctx.Done():
+			return
+		}
+
+		b.mu.Lock()
+		steps := b.pending
+		b.pending = buildSteps{}
+		bctx, cancel := context.WithTimeout(ctx, Config.BuildTimeoutDuration())
+		b.running = steps
+		b.cancel = cancel
+		// The outputs are about to change under the check.
+		if b.cancelBudgets != nil {
+			b.cancelBudgets()
+			b.cancelBudgets = nil
+		}
+		b.mu.Unlock()
+
+		if !steps.empty() {
+			if res := b.build(bctx, steps); res != nil {
+				b.checkSizeBudgets(ctx, res, steps)
+			}
+		}
+
+		b.mu.Lock()
+		cancel()
+		b.cancel = nil
+		b.mu.Unlock()
+	}
+}
+
+// build runs the build steps while holding the wasm requests, and tells the
+// browsers about the result. It returns the result of a successful build, or
+// nil.
+func (b *devBuilder) build(ctx context.Context, steps buildSteps) *buildResult {
+	if steps.Wasm {
+		wasmGate.begin()
+	}
+	events.publish(eventBuilding, "")
+	res := build(ctx, steps)
+	wasmGate.end()
+
+	if errors.Is(res.Err, context.Canceled) {
+		log.Printf("Build cancelled after %s", res.Duration)
+		return nil
+	}
+	if res.Err != nil {
+		log.Printf("Build failed in %s: %v", res.Duration, res.Err)
+		events.publish(eventBuildError, res.Err.Error())
+		return nil
+	}
+	log.Printf("Build finished in %s", res.Duration)
+	events.publish(eventReload, "")
+	return res
+}
+
+// checkSizeBudgets warns about the outputs of res that are over their size
+// budgets. The check runs in the background, so that it doesn't hold the next
+// build, and is cancelled when the next build starts or ctx is done.
+func (b *devBuilder) checkSizeBudgets(ctx context.Context, res *buildResult, steps buildSteps) {
+	if len(Config.SizeBudgets) == 0 {
+		return
+	}
+	cctx, cancel := context.WithCancel(ctx)
+	b.mu.Lock()
+	b.cancelBudgets = cancel
+	b.mu.Unlock()
+	go func() {
+		defer cancel()
+		warnSizeBudgets(cctx, res, steps)
+	}()
+}
+
+// withoutTailwindInputs removes the Tailwind inputs from the css step, since
+// the tailwind --watch processes build them.
+func withoutTailwindInputs(steps buildSteps, inputs []string) buildSteps {
+	if !steps.Css {
+		return steps
+	}
+	candidates := steps.CssInputs
+	if len(candidates) == 0 {
+		candidates = inputs
+	}
+	tw := map[string]bool{}
+	for _, f := range tailwindInputs(candidates) {
+		tw[f] = true
+	}
+	var plain []string
+	for _, f := range candidates {
+		if !tw[f] {
+			plain = append(plain, f)
+		}
+	}
+	steps.CssInputs = plain
+	if len(plain) == 0 {
+		steps.Css = false
+		steps.Skipped = append(steps.Skipped, "Skipping css: the tailwind --watch processes rebuild it")
+	}
+	return steps
+}
+
+func onlyCssChanged(files []string) bool {
+	for _, f := range files {
+		if !IsStylesheet(f) {
+			return false
+		}
+	}
+	return true
+}
+
+var devCmd = &cobra.Command{
+	Use:     "dev",
+	Aliases: []string{"watch"},
+	Short:   "Serve the project and rebuild it in the same process when files change",
+	Long:    `TODO`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := initConf(); err != nil {
+			log.Fatal(err)
+			return
+		}
+		if err := initProfile(flagProfile); err != nil {
+			log.Fatal(err)
+			return
+		}
+		if err := initTailwind(); err != nil {
+			log.Fatal(err)
+			return
+		}
+
+		fw, err := newFileWatcher()
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+		defer fw.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		signalChan := make(chan os.Signal, 1)
+		signal.Notify(signalChan, os.Interrupt)
+		defer signal.Stop(signalChan)
+
+		initCssFiles()
+
+		srv := &http.Server{
+			Addr:    ":" + Config.Http,
+			Handler: newServeMux(),
+			// Cancelling ctx closes the event streams, so that Shutdown doesn't wait for them.
+			BaseContext: func(net.Listener) context.Context { return ctx },
+		}
+		go func() {
+			log.Printf("Listening connections on http://localhost%s", srv.Addr)
+			if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatal(err)
+			}
+		}()
+
+		changes := make(chan []string)
+		go fw.watch(ctx, changes)
+
+		var tw *tailwindWatchers
+		if Config.EnableTailwind && Config.TailwindWatch {
+			if tw, err = newTailwindWatchers(ctx); err != nil {
+				log.Fatal(err)
+				return
+			}
+			tw.sync(ctx, tailwindInputs(cssInputs()))
+		}
+
+		builder := newDevBuilder()
+		go builder.run(ctx)
+		if tw != nil {
+			builder.request(withoutTailwindInputs(allSteps, cssInputs()))
+		} else {
+			builder.request(allSteps)
+		}
+		for {
+			select {
+			case files := <-changes:
+				log.Printf("Changed: %s", strings.Join(files, ", "))
+				for _, f := range files {
+					if !IsStylesheet(f) {
+						continue
+					}
+					if _, err := os.Stat(f); errors.Is(err, os.ErrNotExist) {
+						removeCssOutput(f)
+					}
+				}
+				steps := stepsForChanges(files)
+				if tw != nil {
+					inputs := cssInputs()
+					tw.sync(ctx, tailwindInputs(inputs))
+					steps = withoutTailwindInputs(steps, inputs)
+					// The processes reload the browsers when they are done.
+					if steps.empty() && onlyCssChanged(files) {
+						for _, s := range steps.Skipped {
+							log.Print(s)
+						}
+						continue
+					}
+				}
+				builder.request(steps)
+			case <-signalChan:
+				cancel()
+				if tw != nil {
+					tw.stop()
+				}
+				if err := srv.Shutdown(context.Background()); err != nil {
+					log.Print(err)
+				}
+				return
+			}
+		}
+	},
+}
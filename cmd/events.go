@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Events sent to the browsers connected to /_events.
+const (
+	eventBuilding   = "building"
+	eventReload     = "reload"
+	eventBuildError = "build-error"
+)
+
+const eventsScript = `<script>
+(() => {
+  if (!window.EventSource) {
+    return;
+  }
+  const es = new EventSource('/_events');
+  es.addEventListener('reload', () => location.reload());
+  es.addEventListener('build-error', (e) => {
+    let pre = document.getElementById('_wasmserve_error');
+    if (!pre) {
+      pre = document.createElement('pre');
+      pre.id = '_wasmserve_error';
+      pre.style.cssText = 'position:fixed;inset:0;margin:0;padding:1em;overflow:auto;background:#fff;color:#c00;z-index:2147483647';
+      document.body.appendChild(pre);
+    }
+    pre.innerText = e.data;
+  });
+})();
+</script>
+`
+
+type event struct {
+	name string
+	data string
+}
+
+// eventBroker pushes events to the browsers with server-sent events.
+type eventBroker struct {
+	mu      sync.Mutex
+	clients map[chan event]struct{}
+}
+
+var events = &eventBroker{}
+
+func (b *eventBroker) subscribe() chan event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.clients == nil {
+		b.clients = map[chan event]struct{}{}
+	}
+	ch := make(chan event, 8)
+	b.clients[ch] = struct{}{}
+	return ch
+}
+
+func (b *eventBroker) unsubscribe(ch chan event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.clients, ch)
+}
+
+// publish sends an event to every connected browser. Browsers that are too
+// slow to receive it miss the event.
+func (b *eventBroker) publish(name, data string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.clients {
+		select {
+		case ch <- event{name: name, data: data}:
+		default:
+		}
+	}
+}
+
+func (b *eventBroker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ch := b.subscribe()
+	defer b.unsubscribe(ch)
+
+	for {
+		select {
+		case e := <-ch:
+			fmt.Fprintf(w, "event: %s\n", e.name)
+			// Every line of a multi-line payload needs its own data field.
+			for _, l := range strings.Split(e.data, "\n") {
+				fmt.Fprintf(w, "data: %s\n", l)
+			}
+			fmt.Fprint(w, "\n")
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// handleNotify makes the connected browsers reload.
+func handleNotify(w http.ResponseWriter, r *http.Request) {
+	events.publish(eventReload, "")
+}
+
+// buildGate lets requests wait for a running build to finish.
+type buildGate struct {
+	mu   sync.Mutex
+	done chan struct{}
+}
+
+var wasmGate = &buildGate{}
+
+// begin marks a build as running.
+func (g *buildGate) begin() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.done == nil {
+		g.done = make(chan struct{})
+	}
+}
+
+// end marks the running build as finished and releases the waiting requests.
+func (g *buildGate) end() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.done != nil {
+		close(g.done)
+		g.done = nil
+	}
+}
+
+// wait blocks until no build is running. It returns false if ctx is done first.
+func (g *buildGate) wait(ctx context.Context) bool {
+	g.mu.Lock()
+	done := g.done
+	g.mu.Unlock()
+
+	if done == nil {
+		return true
+	}
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
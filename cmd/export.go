@@ -0,0 +1,307 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	. "github.com/hajimehoshi/wasmserve/pkg"
+	"github.com/spf13/cobra"
+)
+
+var flagExportDir string
+var flagBasePath string
+
+// exportSite writes the site built by res to dir, for a static file server
+// that hosts it under the base path base: the index page, wasm_exec.js, the
+// wasm, the css and js outputs, and the public assets, with the precompressed
+// siblings of the wasm, js and css files. The site is written to a temporary
+// directory that replaces dir when it is complete.
+func exportSite(res *buildResult, dir, base string) error {
+	if err := checkExportDir(dir); err != nil {
+		return err
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(abs), 0755); err != nil {
+		return err
+	}
+	out, err := os.MkdirTemp(filepath.Dir(abs), "."+filepath.Base(abs)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(out)
+	if err := os.Chmod(out, 0755); err != nil {
+		return err
+	}
+
+	m, err := readManifest()
+	if err != nil {
+		return err
+	}
+
+	wasmExec := "wasm_exec.js"
+	if _, err := os.Stat(wasmExec); errors.Is(err, os.ErrNotExist) {
+		if wasmExec, err = goWasmExecJs(); err != nil {
+			return err
+		}
+	}
+	if err := exportCopy(filepath.Join(out, "wasm_exec.js"), wasmExec); err != nil {
+		return err
+	}
+	name, f := hashedOutput(Config.WasmFile, Config.WasmPath, m)
+	if err := exportCopy(filepath.Join(out, name), f); err != nil {
+		return err
+	}
+
+	for _, p := range res.CssPaths {
+		name, f := hashedOutput(p.URLPath(), p.Output, m)
+		src, err := os.ReadFile(f)
+		if err != nil {
+			return err
+		}
+		dst := filepath.Join(out, filepath.FromSlash(name))
+		if err := exportFile(dst, RebaseCss(src, base)); err != nil {
+			return err
+		}
+		// The source maps keep their names, which the outputs refer to.
+		mapDst := filepath.Join(out, filepath.FromSlash(p.URLPath())+".map")
+		if err := exportCopy(mapDst, p.Output+".map"); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	for _, f := range res.JsOutputs {
+		rel, err := filepath.Rel(Config.TmpDir, f)
+		if err != nil {
+			return err
+		}
+		name, f := hashedOutput(filepath.ToSlash(rel), f, m)
+		if err := exportCopy(filepath.Join(out, filepath.FromSlash(name)), f); err != nil {
+			return err
+		}
+	}
+	if Config.HashFilenames {
+		if err := m.Write(filepath.Join(out, ManifestFile)); err != nil {
+			return err
+		}
+	}
+
+	assets, err := exportAssets(abs)
+	if err != nil {
+		return err
+	}
+	for _, a := range assets {
+		if err := exportCopy(filepath.Join(out, a), a); err != nil {
+			return err
+		}
+	}
+
+	// The index page is written last, with the integrity of the files it
+	// loads.
+	integrity := func(name string) string {
+		data, err := os.ReadFile(filepath.Join(out, filepath.FromSlash(strings.TrimPrefix(path.Clean("/"+name), "/"))))
+		if err != nil {
+			return ""
+		}
+		return Integrity(data)
+	}
+	// The index page is also the 404 page, so that static hosts such as
+	// GitHub Pages fall back to it for unknown paths like the dev server. The
+	// 404 page is served for nested paths too, so it always has a <base>
+	// element that resolves its relative URLs from the base path.
+	var index []byte
+	if _, err := os.Stat("index.html"); errors.Is(err, os.ErrNotExist) {
+		index = []byte(indexPage(nil, "", m, integrity))
+	} else if ok, err := isIndexTemplate("index.html"); err != nil {
+		return err
+	} else if ok {
+		if index, err = renderIndexFile("index.html", m, integrity); err != nil {
+			return err
+		}
+	} else if index, err = os.ReadFile("index.html"); err != nil {
+		return err
+	}
+	index = RebaseHTML(index, base)
+	if err := exportFile(filepath.Join(out, "index.html"), index); err != nil {
+		return err
+	}
+	if err := exportFile(filepath.Join(out, "404.html"), AddHTMLBase(index, base)); err != nil {
+		return err
+	}
+
+	if Config.ExportPrecompress() {
+		if err := precompressDir(out); err != nil {
+			return err
+		}
+	}
+	if err := exportFile(filepath.Join(out, ExportMarkerFile), []byte("This site was written by wasmserve export, which replaces this directory.\n")); err != nil {
+		return err
+	}
+
+	if err := os.RemoveAll(abs); err != nil {
+		return err
+	}
+	return os.Rename(out, abs)
+}
+
+// precompressDir writes the brotli and gzip siblings of the wasm, js and css
+// files in dir.
+func precompressDir(dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !IsPrecompressible(path) {
+			return err
+		}
+		return Precompress(path)
+	})
+}
+
+// hashedOutput returns the URL path and the file of the output at the URL path
+// name, which is the file file, or of its hashed copy if it has one.
+func hashedOutput(name, file string, m Manifest) (string, string) {
+	h := m.Asset(name)
+	return h, filepath.Join(filepath.Dir(file), path.Base(h))
+}
+
+// checkExportDir returns an error if dir can't be replaced by the site:
+// because it contains the project, is tmp_dir, holds the files of the assets
+// or watch globs, or has files that no export wrote.
+func checkExportDir(dir string) error {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	if abs == wd || strings.HasPrefix(wd, abs+string(filepath.Separator)) {
+		return fmt.Errorf("can't export to %s: it contains the project", dir)
+	}
+	if tmp, err := filepath.Abs(Config.TmpDir); err == nil && tmp == abs {
+		return fmt.Errorf("can't export to %s: it is tmp_dir", dir)
+	}
+	for _, g := range append(Config.ExportAssets(), Config.WatchInclude()...) {
+		d := GlobDir(g)
+		if d == "." {
+			continue
+		}
+		src, err := filepath.Abs(filepath.FromSlash(d))
+		if err != nil {
+			return err
+		}
+		if src == abs || strings.HasPrefix(src, abs+string(filepath.Separator)) {
+			return fmt.Errorf("can't export to %s: it holds the files of %q", dir, g)
+		}
+	}
+	ok, err := CanReplaceExportDir(abs)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("can't export to %s: it isn't empty and wasn't written by wasmserve export; remove it or choose another directory", dir)
+	}
+	return nil
+}
+
+// exportAssets returns the files matching the [export] assets globs. The
+// export directory, tmp_dir and hidden directories are skipped.
+func exportAssets(exportDir string) ([]string, error) {
+	globs := Config.ExportAssets()
+	tmp, err := filepath.Abs(Config.TmpDir)
+	if err != nil {
+		return nil, err
+	}
+	var assets []string
+	err = filepath.Walk(".", func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			abs, err := filepath.Abs(path)
+			if err != nil {
+				return err
+			}
+			if path != "." && (strings.HasPrefix(info.Name(), ".") || abs == tmp || abs == exportDir) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		for _, g := range globs {
+			if MatchGlob(g, filepath.ToSlash(path)) {
+				assets = append(assets, path)
+				break
+			}
+		}
+		return nil
+	})
+	return assets, err
+}
+
+func exportFile(dst string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(dst, data, 0644)
+}
+
+func exportCopy(dst, src string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return exportFile(dst, data)
+}
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Build the project and write a static site to deploy",
+	Long: `Export builds the project and writes a self-contained site to the export
+directory: index.html, wasm_exec.js, the wasm, the css and js outputs and the
+public assets. The site works from any static file server. Use --base-path
+to host it under a subdirectory, such as a GitHub Pages project site. The
+release profile is used unless --profile selects another.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := initConf(); err != nil {
+			log.Fatal(err)
+			return
+		}
+		if err := initProfile(flagExportProfile); err != nil {
+			log.Fatal(err)
+			return
+		}
+		if err := initTailwind(); err != nil {
+			log.Fatal(err)
+			return
+		}
+		dir := Config.ExportDir()
+		if cmd.Flags().Changed("out") {
+			dir = flagExportDir
+		}
+		base := Config.Export.BasePath
+		if cmd.Flags().Changed("base-path") {
+			base = flagBasePath
+		}
+		if err := checkExportDir(dir); err != nil {
+			log.Fatal(err)
+		}
+
+		logJsMinifySizes = true
+		res := runBuild(allSteps)
+		if res.Err != nil {
+			log.Fatal(res.Err)
+		}
+		if err := enforceSizeBudgets(res, allSteps); err != nil {
+			log.Fatal(err)
+		}
+		if err := exportSite(res, dir, NormalizeBasePath(base)); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Exported to %s for %s", dir, NormalizeBasePath(base))
+	},
+}
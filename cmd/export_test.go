@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	. "github.com/hajimehoshi/wasmserve/pkg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExport404Assets(t *testing.T) {
+	defer useTestProject(t, nil, nil)()
+	Config.WasmPath = filepath.Join(Config.TmpDir, Config.WasmFile)
+	assert.Nil(t, os.MkdirAll(Config.TmpDir, 0755))
+	assert.Nil(t, os.WriteFile(Config.WasmPath, []byte("\x00asm"), 0644))
+	assert.Nil(t, os.WriteFile("wasm_exec.js", []byte("// wasm_exec.js"), 0644))
+
+	baseRe := regexp.MustCompile(`<base href="([^"]*)">`)
+	urlRe := regexp.MustCompile(`(?:src|fetch)\(?\s*=?\s*["']([^"']+)["']`)
+	for _, base := range []string{"/", "/repo/"} {
+		assert.Nil(t, exportSite(&buildResult{}, "dist", base), base)
+		page, err := os.ReadFile(filepath.Join("dist", "404.html"))
+		assert.Nil(t, err, base)
+
+		// The 404 page is served for a nested path, such as a route of the
+		// app, and must still load its assets from the base path.
+		m := baseRe.FindSubmatch(page)
+		if !assert.NotNil(t, m, base) {
+			continue
+		}
+		pageURL, _ := url.Parse("http://example.com" + base + "a/b")
+		baseURL, err := pageURL.Parse(string(m[1]))
+		assert.Nil(t, err, base)
+		urls := urlRe.FindAllSubmatch(page, -1)
+		assert.NotEmpty(t, urls, base)
+		for _, u := range urls {
+			ref, err := baseURL.Parse(string(u[1]))
+			assert.Nil(t, err, base)
+			if ref.Host != pageURL.Host {
+				continue
+			}
+			p := strings.TrimPrefix(ref.Path, base)
+			assert.FileExists(t, filepath.Join("dist", filepath.FromSlash(p)), base)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	. "github.com/hajimehoshi/wasmserve/pkg"
+)
+
+// goPackage is the part of the output of go list -json that wasmserve uses.
+type goPackage struct {
+	Dir        string
+	GoFiles    []string
+	CgoFiles   []string
+	EmbedFiles []string
+	Standard   bool
+	Module     *goModule
+}
+
+type goModule struct {
+	Path    string
+	Version string
+	Dir     string
+	GoMod   string
+	Main    bool
+	Replace *goModule
+}
+
+// local reports whether the module's files can change, which is the case for
+// the main and workspace modules and for modules replaced with a directory.
+func (m *goModule) local() bool {
+	if m == nil {
+		// GOPATH mode
+		return true
+	}
+	return m.Main || (m.Replace != nil && m.Replace.Version == "")
+}
+
+// buildGraph holds the local files that the wasm build reads, as reported by
+// go list for GOOS=js GOARCH=wasm and the configured build tags. Test files and
+// files excluded by build constraints are not part of it.
+type buildGraph struct {
+	// files and dirs are absolute paths.
+	files map[string]bool
+	dirs  map[string]bool
+}
+
+func loadBuildGraph() (*buildGraph, error) {
+	args := []string{"list", "-e", "-deps", "-json"}
+	args = append(args, goBuildFlags()...)
+	args = append(args, ".")
+
+	cmdList := exec.Command("go", args...)
+	cmdList.Env = wasmEnv()
+	cmdList.Dir = Config.Root
+	var stderr bytes.Buffer
+	cmdList.Stderr = &stderr
+	out, err := cmdList.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go list: %v\n%s", err, stderr.String())
+	}
+
+	g := &buildGraph{
+		files: map[string]bool{},
+		dirs:  map[string]bool{},
+	}
+	dec := json.NewDecoder(bytes.NewReader(out))
+	for {
+		var p goPackage
+		if err := dec.Decode(&p); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("go list: %v", err)
+		}
+		if p.Standard || !p.Module.local() {
+			continue
+		}
+		g.dirs[p.Dir] = true
+		for _, fs := range [][]string{p.GoFiles, p.CgoFiles, p.EmbedFiles} {
+			for _, f := range fs {
+				g.files[filepath.Join(p.Dir, f)] = true
+			}
+		}
+		if m := p.Module; m != nil {
+			if m.Replace != nil {
+				m = m.Replace
+			}
+			if m.GoMod != "" {
+				g.addModFile(m.GoMod)
+				g.addModFile(strings.TrimSuffix(m.GoMod, ".mod") + ".sum")
+			}
+		}
+	}
+
+	cmdEnv := exec.Command("go", "env", "GOWORK")
+	cmdEnv.Dir = Config.Root
+	if out, err := cmdEnv.Output(); err == nil {
+		if w := strings.TrimSpace(string(out)); w != "" && w != "off" {
+			g.addModFile(w)
+			g.addModFile(w + ".sum")
+		}
+	}
+	return g, nil
+}
+
+func (g *buildGraph) addModFile(path string) {
+	g.files[path] = true
+	g.dirs[filepath.Dir(path)] = true
+}
+
+// contains reports whether the file name is read by the build.
+func (g *buildGraph) contains(name string) bool {
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return false
+	}
+	return g.files[abs]
+}
+
+// mayContain reports whether the file name might be read by the build after
+// the graph is reloaded, because it is a new Go file in one of the package
+// directories.
+func (g *buildGraph) mayContain(name string) bool {
+	if g.contains(name) {
+		return true
+	}
+	if filepath.Ext(name) != ".go" || strings.HasSuffix(name, "_test.go") {
+		return false
+	}
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return false
+	}
+	return g.dirs[filepath.Dir(abs)]
+}
+
+// goFiles returns the Go files of the graph.
+func (g *buildGraph) goFiles() []string {
+	var files []string
+	for f := range g.files {
+		if filepath.Ext(f) == ".go" {
+			files = append(files, f)
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// goGlobs returns the globs of the Go files in the package directories, such
+// as "/home/me/app/*.go".
+func (g *buildGraph) goGlobs() []string {
+	dirs := map[string]bool{}
+	for _, f := range g.goFiles() {
+		dirs[filepath.Dir(f)] = true
+	}
+	globs := make([]string, 0, len(dirs))
+	for d := range dirs {
+		globs = append(globs, filepath.Join(d, "*.go"))
+	}
+	sort.Strings(globs)
+	return globs
+}
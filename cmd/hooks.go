@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	. "github.com/hajimehoshi/wasmserve/pkg"
+)
+
+// hookEnv returns the environment of a hook run in the given stage. res is nil
+// for the pre_build stage, since nothing has been built yet.
+func hookEnv(stage string, res *buildResult) []string {
+	env := append(os.Environ(),
+		"WASMSERVE_HOOK="+stage,
+		"WASMSERVE_ROOT="+Config.Root,
+		"WASMSERVE_TMP_DIR="+Config.TmpDir,
+		"WASMSERVE_WASM_PATH="+Config.WasmPath,
+		"WASMSERVE_PROFILE="+Config.BuildProfile().Name,
+	)
+	if res == nil {
+		return env
+	}
+
+	outputs := make([]string, 0, len(res.CssPaths))
+	for _, c := range res.CssPaths {
+		outputs = append(outputs, c.Output)
+	}
+	env = append(env,
+		"WASMSERVE_CSS_OUTPUTS="+strings.Join(outputs, string(filepath.ListSeparator)),
+		"WASMSERVE_JS_OUTPUTS="+strings.Join(res.JsOutputs, string(filepath.ListSeparator)),
+		"WASMSERVE_BUILD_SUCCESS="+strconv.FormatBool(res.Err == nil),
+		"WASMSERVE_BUILD_DURATION_MS="+strconv.FormatInt(res.Duration.Milliseconds(), 10),
+	)
+	if res.Err != nil {
+		env = append(env, "WASMSERVE_BUILD_ERROR="+res.Err.Error())
+	}
+	return env
+}
+
+func shellCommand(ctx context.Context, line string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", line)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", line)
+}
+
+// commandLine returns the command line of c for errors and logs, quoted so
+// that it can be run again as it is.
+func commandLine(c *exec.Cmd) string {
+	return Command(c.Args).String()
+}
+
+// runHooks runs the hooks of a stage in order. A failing hook stops the
+// remaining ones unless its failure policy is HookWarn.
+func runHooks(ctx context.Context, stage string, hooks []Hook, res *buildResult) error {
+	for _, h := range hooks {
+		log.Printf("%s: %s", stage, h.Cmd)
+		c := shellCommand(ctx, h.Cmd)
+		c.Dir = h.Dir
+		c.Env = hookEnv(stage, res)
+		c.Stdout = os.Stdout
+		c.Stderr = os.Stderr
+		if err := c.Run(); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if h.OnFailure == HookWarn {
+				log.Printf("%s hook %q failed: %v, continuing", stage, h.Cmd, err)
+				continue
+			}
+			return fmt.Errorf("%s hook %q failed: %w", stage, h.Cmd, err)
+		}
+	}
+	return nil
+}
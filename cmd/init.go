@@ -2,12 +2,8 @@ package cmd
 
 import (
 	"fmt"
-	"io"
 	"log"
-	"net/http"
 	"os"
-	"runtime"
-	"strings"
 
 	"github.com/AlecAivazis/survey/v2"
 	"github.com/pelletier/go-toml"
@@ -16,32 +12,41 @@ import (
 	. "github.com/hajimehoshi/wasmserve/pkg"
 )
 
+const (
+	tailwindV4 = "v4 (configuration in css)"
+	tailwindV3 = "v3 (tailwind.config.js)"
+
+	// tailwindV3Release is the last v3 release. The latest release is v4.
+	tailwindV3Release = "v3.4.17"
+
+	defaultTailwindCssPath = "styles.css"
+)
+
+// defaultTailwindCss is the stylesheet of a Tailwind v4 project. Tailwind
+// skips the files ignored by git, so the Go files are listed explicitly.
+const defaultTailwindCss = `@import "tailwindcss";
+
+@source "./**/*.go";
+`
+
 const defaultTailwindConfig = `module.exports = {
-	content: ["./**/*.{html,go}"],
+	content: ["./**/*.{html,go}", "./tmp/tailwind-classes.txt"],
 	theme: {
 	  extend: {},
 	},
 	plugins: [],
 }`
 
-func getOsName() string {
-	switch runtime.GOOS {
-	case "darwin":
-		return "macos"
-	case "linux":
-		return "linux"
-	default:
-		return "windows"
+// writeIfNotExist writes a scaffolded file, keeping the one that already exists.
+func writeIfNotExist(name, content string) {
+	if _, err := os.Stat(name); err == nil {
+		fmt.Printf("%s already exists\n", name)
+		return
 	}
-}
-
-func getArchitecture() string {
-	// Tailwindcss executable only supports x64 and arm64
-	if strings.Contains(runtime.GOARCH, "arm") {
-		return "arm64"
-	} else {
-		return "x64"
+	if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+		log.Fatal(err)
 	}
+	fmt.Printf("%s created\n", name)
 }
 
 func __init() {
@@ -53,7 +58,7 @@ func __init() {
 	if enableTailwind {
 		var (
 			NPX    = "Use npx tailwindcss"
-			LOCAL  = "Download tailwindcss executable to local directory"
+			LOCAL  = "Download a pinned tailwindcss executable to the user cache"
 			MANUAL = "Manual"
 		)
 		var tailwindExec string
@@ -62,59 +67,45 @@ func __init() {
 			Options: []string{NPX, LOCAL, MANUAL},
 		}
 		survey.AskOne(prompt, &tailwindExec)
+		version := tailwindV4
+		survey.AskOne(&survey.Select{
+			Message: "Which tailwindcss version would you like to use:",
+			Options: []string{tailwindV4, tailwindV3},
+		}, &version)
 		tomlConfig.EnableTailwind = true
 		switch tailwindExec {
 		case NPX:
-			tomlConfig.TailwindExec = "npx tailwindcss"
+			if version == tailwindV4 {
+				// The CLI is a separate package since v4.
+				tomlConfig.TailwindExec = Command{"npx", "@tailwindcss/cli"}
+			} else {
+				tomlConfig.TailwindExec = Command{"npx", "tailwindcss@3"}
+			}
 		case MANUAL:
-			tomlConfig.TailwindExec = "CUSTOM TAILWIND BUILD"
+			tomlConfig.TailwindExec = Command{"CUSTOM TAILWIND BUILD"}
 			fmt.Printf("Remember to specify your tailwind build command in wasmserve.toml\n")
 		case LOCAL:
-			url := fmt.Sprintf(
-				"https://github.com/tailwindlabs/tailwindcss/releases/latest/download/tailwindcss-%s-%s",
-				getOsName(),
-				getArchitecture(),
-			)
-			fmt.Printf("Downloading: %s\n", url)
-
-			tailfile := "tailwindcss"
-			resp, err := http.Get(url)
-			if err != nil {
-				log.Fatal(err)
+			release := TailwindLatest
+			if version == tailwindV3 {
+				release = tailwindV3Release
 			}
-			defer resp.Body.Close()
-
-			out, err := os.Create(tailfile)
-			if err != nil {
-				log.Fatal(err)
-			}
-
-			defer out.Close()
-
-			_, err = io.Copy(out, resp.Body)
-
+			v, p, err := installTailwind(release, false)
 			if err != nil {
 				log.Fatal(err)
 			}
-
-			err = os.Chmod(tailfile, 0755)
-			if err != nil {
-				log.Fatal(err)
-			}
-
-			fmt.Println("Tailwind executable downloaded")
-
-			tomlConfig.TailwindExec = fmt.Sprintf("./%s", tailfile)
+			fmt.Printf("Tailwind %s downloaded to %s\n", v, p)
+			// The executable is pinned, and downloaded again on other machines.
+			tomlConfig.TailwindVersion = v
 		}
-		defaultTailwind := true
-		useDefault := &survey.Confirm{Message: "Would you like to use the default tailwind.config.js?"}
-		survey.AskOne(useDefault, &enableTailwind)
-		if defaultTailwind {
-			e := os.WriteFile("tailwind.config.js", []byte(defaultTailwindConfig), 0644)
-			if e != nil {
-				log.Fatal(e)
+		if version == tailwindV4 {
+			writeIfNotExist(defaultTailwindCssPath, defaultTailwindCss)
+		} else {
+			defaultTailwind := true
+			useDefault := &survey.Confirm{Message: "Would you like to use the default tailwind.config.js?"}
+			survey.AskOne(useDefault, &defaultTailwind)
+			if defaultTailwind {
+				writeIfNotExist("tailwind.config.js", defaultTailwindConfig)
 			}
-			fmt.Println("tailwind.config.js created")
 		}
 	}
 	b, err := toml.Marshal(tomlConfig)
@@ -130,7 +121,7 @@ func __init() {
 
 	fmt.Println("\nwasmserve.toml created")
 
-	fmt.Printf("Start your wasm server with: \n\twasmserve --watch\n\n")
+	fmt.Printf("Start your wasm server with: \n\twasmserve dev\n\n")
 
 	fmt.Println("Happy hacking :)")
 }
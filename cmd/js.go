@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/evanw/esbuild/pkg/api"
+	. "github.com/hajimehoshi/wasmserve/pkg"
+)
+
+var jsTargets = map[string]api.Target{
+	"esnext": api.ESNext,
+	"es5":    api.ES5,
+	"es2015": api.ES2015,
+	"es2016": api.ES2016,
+	"es2017": api.ES2017,
+	"es2018": api.ES2018,
+	"es2019": api.ES2019,
+	"es2020": api.ES2020,
+	"es2021": api.ES2021,
+	"es2022": api.ES2022,
+	"es2023": api.ES2023,
+	"es2024": api.ES2024,
+	"es2025": api.ES2025,
+}
+
+var jsFormats = map[string]api.Format{
+	JsFormatESM:  api.FormatESModule,
+	JsFormatIIFE: api.FormatIIFE,
+	JsFormatCJS:  api.FormatCommonJS,
+}
+
+// jsOutputPath returns the path of the bundle of the entry point entry.
+func jsOutputPath(entry string) string {
+	return tmpOutputPath(JsOutputName(entry))
+}
+
+// jsOutput returns the bundle served at the URL path p, which is the path of
+// its entry point with the extension of js, or an empty string if there is
+// none.
+func jsOutput(p string) string {
+	for _, e := range Config.Js.Entries {
+		if "/"+strings.TrimPrefix(filepath.ToSlash(filepath.Clean(JsOutputName(e))), "/") == p {
+			return jsOutputPath(e)
+		}
+	}
+	return ""
+}
+
+// jsBuildOptions returns the esbuild options that bundle the [js] entries to
+// Config.TmpDir.
+func jsBuildOptions() (api.BuildOptions, error) {
+	target := api.ESNext
+	if Config.Js.Target != "" {
+		t, ok := jsTargets[strings.ToLower(Config.Js.Target)]
+		if !ok {
+			return api.BuildOptions{}, fmt.Errorf("js.target: unknown target %q", Config.Js.Target)
+		}
+		target = t
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return api.BuildOptions{}, err
+	}
+	entries := make([]api.EntryPoint, len(Config.Js.Entries))
+	for i, e := range Config.Js.Entries {
+		// The output paths are relative to Outdir, without the extension.
+		out, err := filepath.Rel(Config.TmpDir, jsOutputPath(e))
+		if err != nil {
+			return api.BuildOptions{}, err
+		}
+		entries[i] = api.EntryPoint{InputPath: e, OutputPath: strings.TrimSuffix(out, ".js")}
+	}
+	sourcemap := api.SourceMapNone
+	if Config.JsSourcemap() {
+		sourcemap = api.SourceMapLinked
+	}
+	return api.BuildOptions{
+		EntryPointsAdvanced: entries,
+		AbsWorkingDir:       wd,
+		Outdir:              Config.TmpDir,
+		Bundle:              true,
+		Format:              jsFormats[Config.JsFormat()],
+		Target:              target,
+		Platform:            api.PlatformBrowser,
+		Sourcemap:           sourcemap,
+		External:            Config.Js.External,
+		MinifyWhitespace:    Config.JsMinifyEnabled(),
+		MinifyIdentifiers:   Config.JsMinifyEnabled(),
+		MinifySyntax:        Config.JsMinifyEnabled(),
+		LogLevel:            api.LogLevelSilent,
+	}, nil
+}
+
+// logJsMinifySizes makes buildJs log how much minifying shrank the bundles.
+// Measuring it bundles the entries a second time, so it is set by the one-off
+// builds of build and export, and not for the rebuilds of dev.
+var logJsMinifySizes bool
+
+// logJsMinify logs how much the minification of the build opts shrank the
+// bundles of result, by bundling them again without it.
+func logJsMinify(opts api.BuildOptions, result api.BuildResult) {
+	opts.MinifyWhitespace = false
+	opts.MinifyIdentifiers = false
+	opts.MinifySyntax = false
+	plain := api.Build(opts)
+	if len(plain.Errors) > 0 {
+		return
+	}
+	sizes := map[string]int{}
+	for _, f := range plain.OutputFiles {
+		sizes[f.Path] = len(f.Contents)
+	}
+	for _, f := range result.OutputFiles {
+		before, ok := sizes[f.Path]
+		if !ok || filepath.Ext(f.Path) != ".js" {
+			continue
+		}
+		name := f.Path
+		if rel, err := filepath.Rel(opts.AbsWorkingDir, f.Path); err == nil {
+			name = rel
+		}
+		log.Printf("minify %s: %s", name, FormatSizeChange(int64(before), int64(len(f.Contents))))
+	}
+}
+
+// buildJs bundles the [js] entries with esbuild and returns the paths of the
+// written files. Like the other artifacts, each file is written next to its
+// path and renamed, so that the server never serves a partially written one.
+func buildJs(ctx context.Context) ([]string, error) {
+	start := time.Now()
+	opts, err := jsBuildOptions()
+	if err != nil {
+		return nil, err
+	}
+	result := api.Build(opts)
+	for _, w := range api.FormatMessages(result.Warnings, api.FormatMessagesOptions{Kind: api.WarningMessage}) {
+		log.Print(strings.TrimSpace(w))
+	}
+	if len(result.Errors) > 0 {
+		msgs := api.FormatMessages(result.Errors, api.FormatMessagesOptions{Kind: api.ErrorMessage})
+		return nil, errors.New(strings.TrimSpace(strings.Join(msgs, "\n")))
+	}
+	// esbuild can't be cancelled, so the outputs of a cancelled build are
+	// dropped instead.
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if logJsMinifySizes && Config.JsMinifyEnabled() {
+		logJsMinify(opts, result)
+	}
+
+	var outputs []string
+	for _, f := range result.OutputFiles {
+		tmppath, err := tempPath(f.Path)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(tmppath, f.Contents, 0644); err != nil {
+			os.Remove(tmppath)
+			return nil, err
+		}
+		if err := os.Rename(tmppath, f.Path); err != nil {
+			os.Remove(tmppath)
+			return nil, err
+		}
+		if rel, err := filepath.Rel(opts.AbsWorkingDir, f.Path); err == nil {
+			outputs = append(outputs, rel)
+		} else {
+			outputs = append(outputs, f.Path)
+		}
+	}
+	log.Printf("Bundled %s in %s", strings.Join(Config.Js.Entries, ", "), time.Since(start))
+	return outputs, nil
+}
@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+
+	. "github.com/hajimehoshi/wasmserve/pkg"
+)
+
+const (
+	// cacheImmutable is the Cache-Control of the hashed files, whose content
+	// never changes.
+	cacheImmutable = "public, max-age=31536000, immutable"
+	// cacheNoCache makes the browsers revalidate the index page, so that
+	// they see the new hashed names after a build.
+	cacheNoCache = "no-cache"
+)
+
+func manifestPath() string {
+	return filepath.Join(Config.TmpDir, ManifestFile)
+}
+
+// readManifest returns the manifest of the hashed files, which is empty
+// unless hash_filenames is set.
+func readManifest() (Manifest, error) {
+	if !Config.HashFilenames {
+		return Manifest{}, nil
+	}
+	return ReadManifest(manifestPath())
+}
+
+// hashOutputs copies the outputs of a build to content-hashed names next to
+// them, and records them in the manifest. The outputs that were not built
+// keep their entries, and the replaced copies are removed.
+func hashOutputs(res *buildResult, steps buildSteps) error {
+	m, err := ReadManifest(manifestPath())
+	if err != nil {
+		return err
+	}
+	outputs, err := buildOutputs(res, steps)
+	if err != nil {
+		return err
+	}
+
+	for name, f := range outputs {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return err
+		}
+		hashed := HashedName(name, data)
+		dst := filepath.Join(filepath.Dir(f), path.Base(hashed))
+		if _, err := os.Stat(dst); errors.Is(err, os.ErrNotExist) {
+			tmp, err := tempPath(dst)
+			if err != nil {
+				return err
+			}
+			if err := os.WriteFile(tmp, data, 0644); err != nil {
+				os.Remove(tmp)
+				return err
+			}
+			if err := os.Rename(tmp, dst); err != nil {
+				os.Remove(tmp)
+				return err
+			}
+		} else if err != nil {
+			return err
+		}
+		if old, ok := m[name]; ok && old != hashed {
+			os.Remove(filepath.Join(filepath.Dir(f), path.Base(old)))
+		}
+		m[name] = hashed
+	}
+	return m.Write(manifestPath())
+}
+
+// serveHashed serves the hashed copy at the URL path of r, if there is one,
+// with headers that let the browsers cache it for good.
+func serveHashed(w http.ResponseWriter, r *http.Request) bool {
+	m, err := readManifest()
+	if err != nil || !m.IsHashed(r.URL.Path) {
+		return false
+	}
+	f := filepath.Join(Config.TmpDir, filepath.FromSlash(strings.TrimPrefix(path.Clean(r.URL.Path), "/")))
+	if _, err := os.Stat(f); err != nil {
+		return false
+	}
+	w.Header().Set("Cache-Control", cacheImmutable)
+	serveFile(w, r, f)
+	return true
+}
+
+// indexFuncs returns the functions of the index templates. {{asset "main.wasm"}}
+// is the hashed name of an output, and {{integrity "main.wasm"}} is the
+// Subresource Integrity of the file the name refers to, or empty if it is not
+// known, such as in the dev server.
+func indexFuncs(m Manifest, integrity func(string) string) template.FuncMap {
+	return template.FuncMap{
+		"asset":     m.Asset,
+		"integrity": func(name string) string { return integrity(m.Asset(name)) },
+	}
+}
+
+func noIntegrity(string) string {
+	return ""
+}
+
+var integrityActionRe = regexp.MustCompile(`\{\{-?\s*integrity\b`)
+
+// isIndexTemplate reports whether the index page of the project, name, is
+// rendered as a template: with hash_filenames, or when it uses integrity.
+func isIndexTemplate(name string) (bool, error) {
+	if Config.HashFilenames {
+		return true, nil
+	}
+	src, err := os.ReadFile(name)
+	if err != nil {
+		return false, err
+	}
+	return integrityActionRe.Match(src), nil
+}
+
+// renderIndexFile renders the index page of the project, which is a template
+// with the functions of indexFuncs.
+func renderIndexFile(name string, m Manifest, integrity func(string) string) ([]byte, error) {
+	src, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	t, err := template.New(name).Funcs(indexFuncs(m, integrity)).Parse(string(src))
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	if err := t.Execute(&b, nil); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
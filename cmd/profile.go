@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+
+	. "github.com/hajimehoshi/wasmserve/pkg"
+)
+
+var flagProfile string
+var flagExportProfile string
+
+// warnWasmOptOnce logs once that wasm-opt is not installed.
+var warnWasmOptOnce sync.Once
+
+// wasmBuildFlags returns the flags of the go build of the wasm: the ones of
+// goBuildFlags and the ones of the build profile.
+func wasmBuildFlags() []string {
+	args := goBuildFlags()
+	p := Config.BuildProfile()
+	if p.Trimpath {
+		args = append(args, "-trimpath")
+	}
+	if p.Ldflags != "" {
+		args = append(args, "-ldflags="+p.Ldflags)
+	}
+	return append(args, p.GoFlags...)
+}
+
+// wasmOptCommand returns the command of wasm-opt without the input and output
+// files, or nil if the build profile doesn't run it or it is not installed.
+func wasmOptCommand() []string {
+	p := Config.BuildProfile()
+	if !p.WasmOpt {
+		return nil
+	}
+	c := Config.WasmOptCommand()
+	path, err := exec.LookPath(c[0])
+	if err != nil {
+		warnWasmOptOnce.Do(func() {
+			log.Printf("Not running wasm-opt for the %s profile: %s is not installed", p.Name, c[0])
+		})
+		return nil
+	}
+	args := append([]string{path}, c[1:]...)
+	return append(args, p.WasmOptArgs...)
+}
+
+// runWasmOpt optimizes the wasm file in place with the command of
+// wasmOptCommand, and logs its size before and after.
+func runWasmOpt(ctx context.Context, command []string, file string) error {
+	before, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+	tmppath, err := tempPath(file)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmppath)
+
+	args := append(append([]string{}, command[1:]...), file, "-o", tmppath)
+	cmd := exec.CommandContext(ctx, command[0], args...)
+	out, err := cmd.CombinedOutput()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err != nil {
+		return fmt.Errorf("wasm-opt: %v\n%s", err, out)
+	}
+	after, err := os.Stat(tmppath)
+	if err != nil {
+		return err
+	}
+	log.Printf("wasm-opt %s: %s", filepath.Base(Config.WasmPath), FormatSizeChange(before.Size(), after.Size()))
+	return os.Rename(tmppath, file)
+}
+
+// initProfile selects the build profile name.
+func initProfile(name string) error {
+	if err := Config.UseProfile(name); err != nil {
+		return err
+	}
+	if name != ProfileDev {
+		log.Printf("Using the %s profile", name)
+	}
+	return nil
+}
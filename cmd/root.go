@@ -26,14 +26,35 @@ var rootCmd = &cobra.Command{
 }
 
 func init() {
-	rootCmd.AddCommand(watchCmd)
 	rootCmd.AddCommand(buildCmd)
 	rootCmd.AddCommand(runCmd)
 	rootCmd.AddCommand(initCmd)
+	rootCmd.AddCommand(devCmd)
+	rootCmd.AddCommand(exportCmd)
+	rootCmd.AddCommand(sizeCmd)
+	rootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cacheCleanCmd)
+	cacheCmd.AddCommand(cacheStatsCmd)
+	rootCmd.AddCommand(tailwindCmd)
+	tailwindCmd.AddCommand(tailwindInstallCmd)
+	tailwindCmd.AddCommand(tailwindUpdateCmd)
+	tailwindCmd.AddCommand(tailwindWhichCmd)
 
 	buildCmd.Flags().StringVarP(&flagConf, "config", "c", DefaultTomlFile, "Which config file to use")
-	watchCmd.Flags().StringVarP(&flagConf, "config", "c", DefaultTomlFile, "Which config file to use")
 	runCmd.Flags().StringVarP(&flagConf, "config", "c", DefaultTomlFile, "Which config file to use")
+	devCmd.Flags().StringVarP(&flagConf, "config", "c", DefaultTomlFile, "Which config file to use")
+	exportCmd.Flags().StringVarP(&flagConf, "config", "c", DefaultTomlFile, "Which config file to use")
+	exportCmd.Flags().StringVarP(&flagExportDir, "out", "o", DefaultExportDir, "Directory to write the site to")
+	exportCmd.Flags().StringVar(&flagBasePath, "base-path", "/", "URL path the site is hosted under, such as /repo/")
+	buildCmd.Flags().StringVar(&flagProfile, "profile", ProfileDev, "Build profile: dev, release or a [profile.<name>] table")
+	devCmd.Flags().StringVar(&flagProfile, "profile", ProfileDev, "Build profile: dev, release or a [profile.<name>] table")
+	exportCmd.Flags().StringVar(&flagExportProfile, "profile", ProfileRelease, "Build profile: dev, release or a [profile.<name>] table")
+	sizeCmd.Flags().StringVarP(&flagConf, "config", "c", DefaultTomlFile, "Which config file to use")
+	sizeCmd.Flags().IntVarP(&flagSizeTop, "top", "n", 20, "How many packages and symbols to list")
+	sizeCmd.Flags().BoolVar(&flagSizeJSON, "json", false, "Print the report as JSON")
+	sizeCmd.Flags().StringVar(&flagSizeDiff, "diff", "", "Compare with the wasm file of another build")
+	cacheCmd.PersistentFlags().StringVarP(&flagConf, "config", "c", DefaultTomlFile, "Which config file to use")
+	tailwindCmd.PersistentFlags().StringVarP(&flagConf, "config", "c", DefaultTomlFile, "Which config file to use")
 
 	// TODO Test http
 	runCmd.Flags().StringVarP(&flagHTTP, "http", "p", DefaultHttp, "HTTP bind address to serve")
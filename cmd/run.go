@@ -4,8 +4,10 @@ import (
 	"bytes"
 	"errors"
 	"flag"
+	"fmt"
 	"io/fs"
 	"log"
+	"mime"
 	"net/http"
 	"os"
 	"os/exec"
@@ -20,12 +22,17 @@ import (
 )
 
 const indexHTML = `<!DOCTYPE html>
-<!-- Polyfill for the old Edge browser -->
-<script src="https://cdn.jsdelivr.net/npm/text-encoding@0.7.0/lib/encoding.min.js"></script>
-<script src="wasm_exec.js"></script>
+<script>
+// Polyfill for the old Edge browser. The third-party script is only loaded by
+// the browsers that need it.
+if (!window.TextEncoder) {
+  document.write('<script src="https://cdn.jsdelivr.net/npm/text-encoding@0.7.0/lib/encoding.min.js" crossorigin="anonymous"><\/script>');
+}
+</script>
+<script src="{{asset "wasm_exec.js"}}"{{with integrity "wasm_exec.js"}} integrity="{{.}}"{{end}}></script>
 <script>
 (async () => {
-  const resp = await fetch('main.wasm');
+  const resp = await fetch('{{asset .WasmFile}}'{{with integrity .WasmFile}}, {integrity: '{{.}}'}{{end}});
   if (!resp.ok) {
     const pre = document.createElement('pre');
     pre.innerText = await resp.text();
@@ -39,7 +46,111 @@ const indexHTML = `<!DOCTYPE html>
   }
 })();
 </script>
-`
+{{.Events}}`
+
+// indexPage returns the default index page, which runs the wasm with the
+// arguments args and includes the script events. The outputs are referred to
+// by their names in the manifest m, and checked against integrity.
+func indexPage(args []string, events string, m Manifest, integrity func(string) string) string {
+	argv := make([]string, 0, len(args))
+	for _, a := range args {
+		argv = append(argv, `"`+template.JSEscapeString(a)+`"`)
+	}
+	t := template.Must(template.New("index.html").Funcs(indexFuncs(m, integrity)).Parse(indexHTML))
+	var b bytes.Buffer
+	t.Execute(&b, struct {
+		Argv     string
+		Events   string
+		WasmFile string
+	}{
+		Argv:     "[" + strings.Join(argv, ", ") + "]",
+		Events:   events,
+		WasmFile: Config.WasmFile,
+	})
+	return b.String()
+}
+
+// serveIndex serves the index page: the index.html of the project, rendered
+// as a template if hash_filenames is set or it uses integrity, or the default
+// page.
+func serveIndex(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", cacheNoCache)
+	m, err := readManifest()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var h []byte
+	if _, err := os.Stat("index.html"); err == nil {
+		if ok, err := isIndexTemplate("index.html"); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		} else if !ok {
+			serveFile(w, r, "index.html")
+			return
+		}
+		if h, err = renderIndexFile("index.html", m, noIntegrity); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	} else {
+		h = []byte(indexPage(flag.Args(), eventsScript, m, noIntegrity))
+	}
+	http.ServeContent(w, r, "index.html", time.Now(), bytes.NewReader(h))
+}
+
+// serveFile serves the file name, or its precompressed sibling, such as
+// name.br, if the client accepts its encoding and it is not older than name.
+func serveFile(w http.ResponseWriter, r *http.Request, name string) {
+	fi, err := os.Stat(name)
+	if err != nil || fi.IsDir() {
+		http.ServeFile(w, r, name)
+		return
+	}
+	accept := r.Header.Get("Accept-Encoding")
+	for _, e := range PrecompressedEncodings {
+		f, err := os.Open(name + e.Ext)
+		if err != nil {
+			continue
+		}
+		w.Header().Set("Vary", "Accept-Encoding")
+		if !AcceptsEncoding(accept, e.Encoding) {
+			f.Close()
+			continue
+		}
+		if cfi, err := f.Stat(); err != nil || cfi.ModTime().Before(fi.ModTime()) {
+			f.Close()
+			continue
+		}
+		defer f.Close()
+		ctype := mime.TypeByExtension(filepath.Ext(name))
+		if ctype == "" {
+			ctype = "application/octet-stream"
+		}
+		w.Header().Set("Content-Type", ctype)
+		w.Header().Set("Content-Encoding", e.Encoding)
+		http.ServeContent(w, r, name, fi.ModTime(), f)
+		return
+	}
+	http.ServeFile(w, r, name)
+}
+
+// goWasmExecJs returns the path of the wasm_exec.js of the Go installation.
+// It is in lib/wasm since Go 1.24, and in misc/wasm before.
+func goWasmExecJs() (string, error) {
+	out, err := exec.Command("go", "env", "GOROOT").Output()
+	if err != nil {
+		return "", fmt.Errorf("go env GOROOT: %v", err)
+	}
+	root := strings.TrimSpace(string(out))
+	for _, dir := range []string{"lib", "misc"} {
+		f := filepath.Join(root, dir, "wasm", "wasm_exec.js")
+		if _, err := os.Stat(f); err == nil {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("no wasm_exec.js in %s", root)
+}
 
 func handle(w http.ResponseWriter, r *http.Request) {
 	// TODO Move to Config
@@ -65,37 +176,35 @@ func handle(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	if Config.HashFilenames && serveHashed(w, r) {
+		return
+	}
+
 	switch filepath.Base(fpath) {
 	case ".":
 		fpath = filepath.Join(fpath, "index.html")
 		fallthrough
 	case "index.html":
+		// serveIndex renders the templates and serves the other pages as
+		// they are.
 		if _, err := os.Stat(fpath); err != nil && !errors.Is(err, fs.ErrNotExist) {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
-		} else if errors.Is(err, fs.ErrNotExist) {
-			fargs := flag.Args()
-			argv := make([]string, 0, len(fargs))
-			for _, a := range fargs {
-				argv = append(argv, `"`+template.JSEscapeString(a)+`"`)
-			}
-			h := strings.ReplaceAll(indexHTML, "{{.Argv}}", "["+strings.Join(argv, ", ")+"]")
-			http.ServeContent(w, r, "index.html", time.Now(), bytes.NewReader([]byte(h)))
-			return
 		}
+		serveIndex(w, r)
+		return
 	case "wasm_exec.js":
 		if _, err := os.Stat(fpath); err != nil && !errors.Is(err, fs.ErrNotExist) {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		} else if errors.Is(err, fs.ErrNotExist) {
-			out, err := exec.Command("go", "env", "GOROOT").Output()
+			f, err := goWasmExecJs()
 			if err != nil {
 				log.Print(err)
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
-			f := filepath.Join(strings.TrimSpace(string(out)), "misc", "wasm", "wasm_exec.js")
-			http.ServeFile(w, r, f)
+			serveFile(w, r, f)
 			return
 		}
 	case Config.WasmFile:
@@ -103,41 +212,82 @@ func handle(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		} else if errors.Is(err, fs.ErrNotExist) {
-			http.ServeFile(w, r, Config.WasmPath)
+			// Don't serve a half-written file while the dev server is building.
+			if !wasmGate.wait(r.Context()) {
+				return
+			}
+			serveFile(w, r, Config.WasmPath)
 			return
 		}
 	}
-	if Config.EnableTailwind {
+	if Config.CssEnabled() {
 		if strings.HasSuffix(r.URL.Path, ".css") {
 			out := cssFiles.GetOutput(r.URL.Path)
+			if out == "" {
+				// The file might have been built by another process after the
+				// server started, at the path that mirrors the URL path.
+				p := &CssPath{Output: tmpOutputPath(filepath.FromSlash(strings.TrimPrefix(path.Clean(r.URL.Path), "/")))}
+				if _, err := os.Stat(p.Output); err == nil {
+					cssFiles.Add(p)
+					out = p.Output
+				}
+			}
 			if out != "" {
-				http.ServeFile(w, r, out)
-			} else {
-				http.Error(w, "css file not found", http.StatusInternalServerError)
+				if _, err := os.Stat(out); err == nil {
+					serveFile(w, r, out)
+					return
+				}
+				cssFiles.Remove(r.URL.Path)
+			}
+			// Not a built file. Serve it as a static file below.
+		}
+		if strings.HasSuffix(r.URL.Path, ".css.map") {
+			// Source maps are next to the built files.
+			if out := cssFiles.GetOutput(strings.TrimSuffix(r.URL.Path, ".map")); out != "" {
+				if _, err := os.Stat(out + ".map"); err == nil {
+					serveFile(w, r, out+".map")
+					return
+				}
+			}
+		}
+	}
+
+	if Config.JsEnabled() {
+		// The bundles are served at the paths of their entry points, and their
+		// source maps next to them.
+		if out := jsOutput(strings.TrimSuffix(r.URL.Path, ".map")); out != "" {
+			if strings.HasSuffix(r.URL.Path, ".map") {
+				out += ".map"
+			}
+			if _, err := os.Stat(out); err == nil {
+				serveFile(w, r, out)
+				return
 			}
-			return
 		}
 	}
 
-	if f, err := os.Stat(filepath.Join(".", r.URL.Path)); errors.Is(err, os.ErrNotExist){
+	if _, err := os.Stat(filepath.Join(".", r.URL.Path)); errors.Is(err, os.ErrNotExist) {
 		if _, err := os.Stat(fpath); err != nil && !errors.Is(err, fs.ErrNotExist) {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		} else if errors.Is(err, fs.ErrNotExist) {
-			fargs := flag.Args()
-			argv := make([]string, 0, len(fargs))
-			for _, a := range fargs {
-				argv = append(argv, `"`+template.JSEscapeString(a)+`"`)
-			}
-			h := strings.ReplaceAll(indexHTML, "{{.Argv}}", "["+strings.Join(argv, ", ")+"]")
-			http.ServeContent(w, r, "index.html", time.Now(), bytes.NewReader([]byte(h)))
+			serveIndex(w, r)
 			return
 		}
-	}else{
-		http.ServeFile(w, r, f.Name())
+	} else {
+		serveFile(w, r, filepath.Join(".", r.URL.Path))
 	}
 }
 
+func newServeMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("/_events", events)
+	mux.HandleFunc("/_notify", handleNotify)
+	mux.HandleFunc("/_size", handleSize)
+	mux.HandleFunc("/", handle)
+	return mux
+}
+
 var runCmd = &cobra.Command{
 	Use:   "run",
 	Short: "Run HTTP server that serves the built webassembly and other static files",
@@ -150,10 +300,9 @@ var runCmd = &cobra.Command{
 
 		initCssFiles()
 
-		http.HandleFunc("/", handle)
-		port := ":"+Config.Http
-		log.Printf("Trying to listen to port: "+port)
+		port := ":" + Config.Http
+		log.Printf("Trying to listen to port: " + port)
 		log.Printf("Listening connections on http://localhost%s", port)
-		log.Fatal(http.ListenAndServe(port, nil))
+		log.Fatal(http.ListenAndServe(port, newServeMux()))
 	},
 }
@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleIndexTemplate(t *testing.T) {
+	defer useTestProject(t, nil, nil)()
+	page := `<!DOCTYPE html>
+<script src="wasm_exec.js" integrity="{{integrity "wasm_exec.js"}}"></script>
+`
+	assert.Nil(t, os.WriteFile("index.html", []byte(page), 0644))
+
+	for _, p := range []string{"/", "/index.html"} {
+		rec := httptest.NewRecorder()
+		newServeMux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, p, nil))
+		assert.Equal(t, http.StatusOK, rec.Code, p)
+		// The integrity is empty in the dev server.
+		assert.Equal(t, `<!DOCTYPE html>
+<script src="wasm_exec.js" integrity=""></script>
+`, rec.Body.String(), p)
+	}
+
+	// A page that is not a template is served as it is.
+	page = `<!DOCTYPE html>
+<p>{{not a template}}</p>
+`
+	assert.Nil(t, os.WriteFile("index.html", []byte(page), 0644))
+	rec := httptest.NewRecorder()
+	newServeMux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, page, rec.Body.String())
+}
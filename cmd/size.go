@@ -0,0 +1,277 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	. "github.com/hajimehoshi/wasmserve/pkg"
+	"github.com/spf13/cobra"
+)
+
+var flagSizeTop int
+var flagSizeJSON bool
+var flagSizeDiff string
+
+// readSizeReport returns the size report of the wasm file name.
+func readSizeReport(name string) (*SizeReport, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	rep, err := WasmSizes(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return rep, nil
+}
+
+func sizeShare(n, total int64) string {
+	if total == 0 {
+		return "-"
+	}
+	return strconv.FormatFloat(float64(n)*100/float64(total), 'f', 1, 64) + "%"
+}
+
+// printSizeReport prints the sections and the top packages and symbols of
+// rep.
+func printSizeReport(out io.Writer, name string, rep *SizeReport, top int) {
+	fmt.Fprintf(out, "%s: %s\n\n", name, FormatSize(rep.Total))
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SECTION\tSIZE\tSHARE\t")
+	for _, s := range rep.Sections {
+		fmt.Fprintf(w, "%s\t%s\t%s\t\n", s.Name, FormatSize(s.Size), sizeShare(s.Size, rep.Total))
+	}
+	w.Flush()
+
+	fmt.Fprintln(out)
+	w = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PACKAGE\tSIZE\tSHARE\tCODE\tDATA\t")
+	for i, p := range rep.Packages {
+		if i == top {
+			fmt.Fprintf(w, "(%d more)\t\t\t\t\t\n", len(rep.Packages)-top)
+			break
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t\n", p.Name, FormatSize(p.Size), sizeShare(p.Size, rep.Total), FormatSize(p.Code), FormatSize(p.Data))
+	}
+	w.Flush()
+
+	fmt.Fprintln(out)
+	w = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SYMBOL\tKIND\tSIZE\tSHARE\t")
+	for i, s := range rep.Symbols {
+		if i == top {
+			fmt.Fprintf(w, "(%d more)\t\t\t\t\n", len(rep.Symbols)-top)
+			break
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t\n", s.Name, s.Kind, FormatSize(s.Size), sizeShare(s.Size, rep.Total))
+	}
+	w.Flush()
+}
+
+// printSizeDiff prints the sections, packages and symbols whose size changed
+// between the builds oldName and newName.
+func printSizeDiff(out io.Writer, oldName, newName string, d *SizeDiff, top int) {
+	fmt.Fprintf(out, "%s -> %s: %s\n", oldName, newName, FormatSizeChange(d.OldTotal, d.NewTotal))
+	tables := []struct {
+		title  string
+		deltas []SizeDelta
+	}{
+		{"SECTION", d.Sections},
+		{"PACKAGE", d.Packages},
+		{"SYMBOL", d.Symbols},
+	}
+	for _, t := range tables {
+		if len(t.deltas) == 0 {
+			continue
+		}
+		fmt.Fprintln(out)
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "%s\tOLD\tNEW\tDELTA\t\n", t.title)
+		for i, s := range t.deltas {
+			if i == top {
+				fmt.Fprintf(w, "(%d more)\t\t\t\t\n", len(t.deltas)-top)
+				break
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t\n", s.Name, FormatSize(s.Old), FormatSize(s.New), FormatSizeDelta(s.Delta))
+		}
+		w.Flush()
+	}
+}
+
+// handleSize serves the size report of the wasm as a treemap, or as JSON with
+// ?format=json.
+func handleSize(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", cacheNoCache)
+	rep, err := readSizeReport(Config.WasmPath)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if r.URL.Query().Get("format") == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(rep)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := sizeTemplate.Execute(w, struct {
+		File   string
+		Total  string
+		Report *SizeReport
+	}{Config.WasmPath, FormatSize(rep.Total), rep}); err != nil {
+		log.Print(err)
+	}
+}
+
+var sizeTemplate = htmltemplate.Must(htmltemplate.New("size").Parse(`<!DOCTYPE html>
+<meta charset="utf-8">
+<title>{{.File}}: {{.Total}}</title>
+<style>
+body { margin: 0; font: 12px sans-serif; }
+header { padding: 8px; }
+#map { position: absolute; top: 32px; left: 0; right: 0; bottom: 0; }
+#map div { position: absolute; box-sizing: border-box; overflow: hidden; border: 1px solid #fff; padding: 2px; white-space: nowrap; }
+#map .pkg { border-color: #333; padding: 0; }
+#map .pkg > span { display: block; padding: 2px; font-weight: bold; }
+</style>
+<header>{{.File}}: {{.Total}}. Hover for the sizes, or get the <a href="?format=json">JSON</a>.</header>
+<div id="map"></div>
+<script>
+const report = {{.Report}};
+
+function formatSize(n) {
+  if (n >= 1 << 20) return (n / (1 << 20)).toFixed(1) + 'MB';
+  if (n >= 1 << 10) return (n / (1 << 10)).toFixed(1) + 'KB';
+  return n + 'B';
+}
+
+function color(name) {
+  let h = 0;
+  for (const c of name) h = (h * 31 + c.charCodeAt(0)) % 360;
+  return 'hsl(' + h + ', 50%, 75%)';
+}
+
+// squarify lays the items out in the rectangle r, as rows that keep the
+// rectangles close to squares.
+function squarify(items, r) {
+  const out = [];
+  const total = items.reduce((s, i) => s + i.size, 0);
+  if (!total) return out;
+  const scale = r.w * r.h / total;
+  let rest = items.map(i => ({item: i, area: i.size * scale}));
+  let x = r.x, y = r.y, w = r.w, h = r.h;
+  const worst = (row, side) => {
+    const s = row.reduce((a, i) => a + i.area, 0);
+    let m = 0;
+    for (const i of row) m = Math.max(m, side * side * i.area / (s * s), s * s / (side * side * i.area));
+    return m;
+  };
+  while (rest.length) {
+    const side = Math.min(w, h);
+    let row = [rest[0]];
+    let i = 1;
+    while (i < rest.length && worst(row.concat(rest[i]), side) <= worst(row, side)) row.push(rest[i++]);
+    rest = rest.slice(i);
+    const s = row.reduce((a, i) => a + i.area, 0);
+    let off = 0;
+    for (const e of row) {
+      const len = s ? e.area / s * side : 0;
+      if (w >= h) out.push({item: e.item, x: x, y: y + off, w: s / h, h: len});
+      else out.push({item: e.item, x: x + off, y: y, w: len, h: s / w});
+      off += len;
+    }
+    if (w >= h) { x += s / h; w -= s / h; } else { y += s / w; h -= s / w; }
+  }
+  return out;
+}
+
+function draw() {
+  const map = document.getElementById('map');
+  map.textContent = '';
+  const bySize = (a, b) => b.size - a.size;
+  const symbols = {};
+  for (const s of report.symbols) (symbols[s.package] = symbols[s.package] || []).push(s);
+  const pkgs = report.packages.filter(p => p.size > 0).sort(bySize);
+  const box = {x: 0, y: 0, w: map.clientWidth, h: map.clientHeight};
+  for (const p of squarify(pkgs, box)) {
+    const pkg = p.item;
+    const d = document.createElement('div');
+    d.className = 'pkg';
+    Object.assign(d.style, {left: p.x + 'px', top: p.y + 'px', width: p.w + 'px', height: p.h + 'px', background: color(pkg.name)});
+    d.title = pkg.name + ': ' + formatSize(pkg.size);
+    const label = document.createElement('span');
+    label.textContent = pkg.name + ' ' + formatSize(pkg.size);
+    d.appendChild(label);
+    map.appendChild(d);
+    const inner = {x: 0, y: 16, w: p.w, h: Math.max(0, p.h - 16)};
+    if (inner.w < 4 || inner.h < 4) continue;
+    for (const s of squarify((symbols[pkg.name] || []).sort(bySize), inner)) {
+      if (s.w < 2 || s.h < 2) continue;
+      const e = document.createElement('div');
+      Object.assign(e.style, {left: s.x + 'px', top: s.y + 'px', width: s.w + 'px', height: s.h + 'px'});
+      e.title = s.item.name + ' (' + s.item.kind + '): ' + formatSize(s.item.size);
+      e.textContent = s.item.name;
+      d.appendChild(e);
+    }
+  }
+}
+
+draw();
+window.addEventListener('resize', draw);
+</script>
+`))
+
+var sizeCmd = &cobra.Command{
+	Use:   "size [file.wasm]",
+	Short: "Break the size of the wasm down by Go package and symbol",
+	Long: `Size reports what the built wasm is made of: the size of its sections, and
+the code and data of its Go packages and symbols, from the code, data and name
+sections and the pclntab of the Go runtime. The file defaults to the wasm of
+the last build. --diff compares it with another build. The dev server serves
+the report as a treemap at /_size.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := initConf(); err != nil {
+			log.Fatal(err)
+			return
+		}
+		name := Config.WasmPath
+		if len(args) > 0 {
+			name = args[0]
+		}
+		rep, err := readSizeReport(name)
+		if os.IsNotExist(err) && len(args) == 0 {
+			log.Fatalf("%s doesn't exist: run wasmserve build first", name)
+		} else if err != nil {
+			log.Fatal(err)
+		}
+
+		var v interface{} = rep
+		if flagSizeDiff != "" {
+			old, err := readSizeReport(flagSizeDiff)
+			if err != nil {
+				log.Fatal(err)
+			}
+			d := DiffSizes(old, rep)
+			if !flagSizeJSON {
+				printSizeDiff(os.Stdout, flagSizeDiff, name, d, flagSizeTop)
+				return
+			}
+			v = d
+		} else if !flagSizeJSON {
+			printSizeReport(os.Stdout, name, rep, flagSizeTop)
+			return
+		}
+		e := json.NewEncoder(os.Stdout)
+		e.SetIndent("", "  ")
+		if err := e.Encode(v); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
@@ -0,0 +1,236 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+
+	. "github.com/hajimehoshi/wasmserve/pkg"
+)
+
+// cssProcessor turns a stylesheet into css.
+type cssProcessor interface {
+	// process builds input to output. source is the stylesheet the build
+	// started from, which the processors before this one have built to
+	// input. A source map written next to output, as output + ".map", is
+	// kept if css_source_maps is set.
+	process(ctx context.Context, source, input, output string) error
+}
+
+var cssProcessors = map[string]cssProcessor{
+	CssProcessorTailwind: tailwindProcessor{},
+	CssProcessorNative:   nativeProcessor{},
+	CssProcessorCopy:     copyProcessor{},
+	CssProcessorSass:     sassProcessor{},
+	CssProcessorPostcss:  postcssProcessor{},
+}
+
+type tailwindProcessor struct{}
+
+func (tailwindProcessor) process(ctx context.Context, source, input, output string) error {
+	args, err := tailwindArgs(tailwindVersion(ctx), source, input, output)
+	if err != nil {
+		return err
+	}
+	c, err := tailwindCommand(ctx, args...)
+	if err != nil {
+		return err
+	}
+	return runCssCommand(ctx, c)
+}
+
+type nativeProcessor struct{}
+
+func (nativeProcessor) process(ctx context.Context, source, input, output string) error {
+	src, err := BundleCss(input)
+	if err != nil {
+		return err
+	}
+	if Config.CssMinifyEnabled() {
+		src = minifyCss(source, src)
+	}
+	return os.WriteFile(output, src, 0644)
+}
+
+type copyProcessor struct{}
+
+func (copyProcessor) process(ctx context.Context, source, input, output string) error {
+	src, err := os.ReadFile(input)
+	if err != nil {
+		return err
+	}
+	if Config.CssMinifyEnabled() {
+		src = minifyCss(source, src)
+	}
+	return os.WriteFile(output, src, 0644)
+}
+
+// minifyCss minifies the css built from the stylesheet source, and logs the
+// size change.
+func minifyCss(source string, src []byte) []byte {
+	out := MinifyCss(src)
+	log.Printf("minify %s: %s", source, FormatSizeChange(int64(len(src)), int64(len(out))))
+	return out
+}
+
+type sassProcessor struct{}
+
+func (sassProcessor) process(ctx context.Context, source, input, output string) error {
+	args := []string{input, output}
+	if Config.CssMinifyEnabled() {
+		// sass minifies the output itself, keeping the source map right.
+		args = append(args, "--style=compressed")
+	}
+	if Config.CssSourceMapsEnabled() {
+		args = append(args, "--source-map")
+	} else {
+		args = append(args, "--no-source-map")
+	}
+	return runCssCommand(ctx, processorCommand(ctx, Config.SassCommand(), args...))
+}
+
+type postcssProcessor struct{}
+
+func (postcssProcessor) process(ctx context.Context, source, input, output string) error {
+	args := []string{input, "-o", output}
+	if Config.CssSourceMapsEnabled() {
+		args = append(args, "--map")
+	} else {
+		args = append(args, "--no-map")
+	}
+	return runCssCommand(ctx, processorCommand(ctx, Config.PostcssCommand(), args...))
+}
+
+func processorCommand(ctx context.Context, c Command, args ...string) *exec.Cmd {
+	ex := c.Args()
+	return exec.CommandContext(ctx, ex[0], append(ex[1:], args...)...)
+}
+
+func runCssCommand(ctx context.Context, c *exec.Cmd) error {
+	out, err := c.CombinedOutput()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %v\n%s", commandLine(c), err, out)
+	}
+	return nil
+}
+
+// cssChain returns the names of the processors that build the stylesheet
+// cssPath, in order: the ones of css_processors, or else Tailwind for the css
+// files with Tailwind directives, and the css_pipeline for the others.
+func cssChain(cssPath string) ([]string, error) {
+	if chain := Config.CssProcessorsFor(cssPath); chain != nil {
+		return chain, nil
+	}
+	if Config.EnableTailwind {
+		ok, err := usesTailwind(cssPath)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return []string{CssProcessorTailwind}, nil
+		}
+	}
+	if Config.CssPipeline == CssPipelineNative {
+		return []string{CssProcessorNative}, nil
+	}
+	return []string{CssProcessorCopy}, nil
+}
+
+// buildCssFile builds the stylesheet cssPath with its processors. They write
+// to a temporary directory next to the output, and the output and its source
+// map are moved into place when all of them are done, so that the server
+// never serves a partially written file.
+func buildCssFile(ctx context.Context, cssPath string) (*CssPath, error) {
+	chain, err := cssChain(cssPath)
+	if err != nil {
+		return nil, err
+	}
+	outpath := cssOutputPath(cssPath)
+	if err := os.MkdirAll(filepath.Dir(outpath), 0755); err != nil {
+		return nil, err
+	}
+	dir, err := os.MkdirTemp(filepath.Dir(outpath), "."+filepath.Base(outpath)+".*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	input := cssPath
+	var output string
+	for i, name := range chain {
+		output = filepath.Join(dir, filepath.Base(outpath))
+		if i < len(chain)-1 {
+			// The intermediate files keep the name of the output, so that
+			// their source maps are found by it.
+			output = filepath.Join(dir, strconv.Itoa(i), filepath.Base(outpath))
+			if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+				return nil, err
+			}
+		}
+		if err := cssProcessors[name].process(ctx, cssPath, input, output); err != nil {
+			return nil, err
+		}
+		input = output
+	}
+	// postcss has no minify option of its own, so its output is minified
+	// afterwards. The source map doesn't follow the minification.
+	if chain[len(chain)-1] == CssProcessorPostcss && Config.CssMinifyEnabled() {
+		src, err := os.ReadFile(output)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(output, minifyCss(cssPath, src), 0644); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := moveSourceMap(output+".map", outpath+".map"); err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(output, 0644); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(output, outpath); err != nil {
+		return nil, err
+	}
+	return &CssPath{Output: outpath, Input: cssPath}, nil
+}
+
+// moveSourceMap moves the source map from to the path to, rewriting its
+// relative sources. Without a source map at from, or with css_source_maps
+// off, a stale source map at to is removed.
+func moveSourceMap(from, to string) error {
+	data, err := os.ReadFile(from)
+	if errors.Is(err, os.ErrNotExist) || err == nil && !Config.CssSourceMapsEnabled() {
+		if err := os.Remove(to); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	fromDir, err := filepath.Abs(filepath.Dir(from))
+	if err != nil {
+		return err
+	}
+	toDir, err := filepath.Abs(filepath.Dir(to))
+	if err != nil {
+		return err
+	}
+	if data, err = RelocateSourceMap(data, fromDir, toDir); err != nil {
+		return fmt.Errorf("%s: %v", from, err)
+	}
+	if err := os.WriteFile(from, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(from, to)
+}
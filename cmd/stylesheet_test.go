@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	. "github.com/hajimehoshi/wasmserve/pkg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNativeProcessorMinify(t *testing.T) {
+	defer useTestProject(t, []string{"css/main.css"}, nil)()
+	src := `@import "parts.css";
+
+body {
+  margin: 0;
+}
+`
+	assert.Nil(t, os.WriteFile("css/main.css", []byte(src), 0644))
+	assert.Nil(t, os.WriteFile("css/parts.css", []byte("p {\n  color: red;\n}\n"), 0644))
+
+	bundle, err := BundleCss("css/main.css")
+	assert.Nil(t, err)
+
+	// The dev profile keeps the bundle as it is.
+	assert.Nil(t, nativeProcessor{}.process(context.Background(), "css/main.css", "css/main.css", "out.css"))
+	got, err := os.ReadFile("out.css")
+	assert.Nil(t, err)
+	assert.Equal(t, string(bundle), string(got))
+
+	Config.CssMinify = true
+	assert.Nil(t, nativeProcessor{}.process(context.Background(), "css/main.css", "css/main.css", "out.css"))
+	got, err = os.ReadFile("out.css")
+	assert.Nil(t, err)
+	assert.Equal(t, string(MinifyCss(bundle)), string(got))
+	assert.NotEqual(t, string(bundle), string(got))
+}
@@ -0,0 +1,515 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	. "github.com/hajimehoshi/wasmserve/pkg"
+)
+
+// tailwindDetectTimeout is how long `tailwindcss --help` may take. npx might
+// have to download the package first.
+const tailwindDetectTimeout = time.Minute
+
+// tailwindJsConfigs are the configuration files Tailwind v3 looks for.
+var tailwindJsConfigs = []string{"tailwind.config.js", "tailwind.config.cjs", "tailwind.config.mjs", "tailwind.config.ts"}
+
+var tailwindVersions = struct {
+	sync.Mutex
+	m map[string]TailwindVersion
+}{m: map[string]TailwindVersion{}}
+
+// tailwindVersion returns the version of tailwind_exec. The executable is run
+// once, and v3 is assumed if its version can't be found.
+func tailwindVersion(ctx context.Context) TailwindVersion {
+	tailwindVersions.Lock()
+	defer tailwindVersions.Unlock()
+
+	if v, ok := tailwindVersions.m[tailwindExecKey()]; ok {
+		return v
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, tailwindDetectTimeout)
+	defer cancel()
+	var out []byte
+	if c, err := tailwindCommand(ctx, "--help"); err == nil {
+		// --help exits with 1 in some versions, so only the output is checked.
+		out, _ = c.CombinedOutput()
+	}
+	v, ok := ParseTailwindVersion(out)
+	if !ok {
+		fallback := TailwindVersion{Major: 3}
+		if ctx.Err() != nil {
+			// Try again next time.
+			return fallback
+		}
+		log.Printf("Could not find the version of %s, assuming Tailwind v3", tailwindExecKey())
+		v = fallback
+	} else {
+		log.Printf("Using Tailwind %s", v)
+	}
+	if v.CssConfig() {
+		if c := tailwindJsConfig(); c != "" {
+			log.Printf("Tailwind %s ignores %s unless [tailwind] config is set or a stylesheet loads it with @config", v, c)
+		}
+	}
+	tailwindVersions.m[tailwindExecKey()] = v
+	return v
+}
+
+// managedTailwindPath is the downloaded executable of tailwind_version, which
+// is used instead of tailwind_exec.
+var managedTailwindPath string
+
+func tailwindExecKey() string {
+	if managedTailwindPath != "" {
+		return managedTailwindPath
+	}
+	return Config.TailwindExec.String()
+}
+
+// tailwindCommand returns the command that runs Config.TailwindExec, or the
+// executable of tailwind_version, with args in tailwind_dir.
+func tailwindCommand(ctx context.Context, args ...string) (*exec.Cmd, error) {
+	var c *exec.Cmd
+	switch {
+	case managedTailwindPath != "":
+		c = exec.CommandContext(ctx, managedTailwindPath, args...)
+	case len(Config.TailwindExec) > 0:
+		ex := Config.TailwindExec.Args()
+		c = exec.CommandContext(ctx, ex[0], append(ex[1:], args...)...)
+	default:
+		return nil, errors.New("neither tailwind_exec nor tailwind_version is set")
+	}
+	c.Dir = Config.TailwindDir
+	return c, nil
+}
+
+// latestTailwind returns the version of the latest release. If installed is
+// true and the release can't be looked up, such as offline, the newest
+// installed version is used instead.
+func latestTailwind(r *TailwindRelease, name string, installed bool) (string, error) {
+	version, err := r.Latest()
+	if err == nil || !installed {
+		return version, err
+	}
+	newest, nerr := r.Newest(name)
+	if nerr != nil || newest == "" {
+		return "", err
+	}
+	log.Printf("Using the installed Tailwind %s: looking up the latest release failed: %v", newest, err)
+	return newest, nil
+}
+
+// installTailwind downloads the standalone executable of version, or of the
+// latest release, unless it is already installed. It returns the version and
+// the path of the executable. If installed is true, the latest release falls
+// back to the newest installed version when it can't be looked up.
+func installTailwind(version string, installed bool) (string, string, error) {
+	r, err := Config.TailwindRelease()
+	if err != nil {
+		return "", "", err
+	}
+	name, err := TailwindExecutableName(runtime.GOOS, runtime.GOARCH)
+	if err != nil {
+		return "", "", err
+	}
+	version, err = NormalizeTailwindVersion(version)
+	if err != nil {
+		return "", "", err
+	}
+	if version == TailwindLatest {
+		if version, err = latestTailwind(r, name, installed); err != nil {
+			return "", "", err
+		}
+	}
+	if _, err := os.Stat(r.Path(version, name)); err != nil {
+		log.Printf("Downloading Tailwind %s (%s)", version, name)
+	}
+	p, err := r.Install(version, name)
+	if err != nil {
+		return "", "", err
+	}
+	return version, p, nil
+}
+
+// initTailwind installs the executable of tailwind_version, if it is set.
+func initTailwind() error {
+	if !Config.EnableTailwind || Config.TailwindVersion == "" {
+		return nil
+	}
+	version, p, err := installTailwind(Config.TailwindVersion, true)
+	if err != nil {
+		return fmt.Errorf("tailwind_version: %v", err)
+	}
+	managedTailwindPath = p
+	// The version is known without running the executable.
+	if v, ok := ParseTailwindVersion([]byte("tailwindcss " + version)); ok {
+		tailwindVersions.Lock()
+		tailwindVersions.m[p] = v
+		tailwindVersions.Unlock()
+	}
+	return nil
+}
+
+// tailwindJsConfig returns the Tailwind v3 configuration file in tailwind_dir,
+// or an empty string if there is none.
+func tailwindJsConfig() string {
+	for _, c := range tailwindJsConfigs {
+		if _, err := os.Stat(filepath.Join(Config.TailwindDir, c)); err == nil {
+			return c
+		}
+	}
+	return ""
+}
+
+// tailwindArgs returns the arguments that build input to output with Tailwind
+// v and the [tailwind] options of the stylesheet source, which is input unless
+// other processors built input from it. Tailwind v3 is given the options as
+// flags. Tailwind v4 has no flags for the configuration file and the content,
+// so it builds a stylesheet that imports input, followed by the @config and
+// @source rules of the options.
+func tailwindArgs(v TailwindVersion, source, input, output string) ([]string, error) {
+	o := Config.TailwindOptionsFor(source)
+	in := input
+	if v.CssConfig() {
+		if o.Postcss != "" {
+			return nil, fmt.Errorf("[tailwind] postcss: Tailwind %s has no PostCSS option, use @tailwindcss/postcss instead", v)
+		}
+		w, err := writeTailwindInput(source, input, o)
+		if err != nil {
+			return nil, err
+		}
+		in = w
+	}
+	args := []string{"-i", tailwindPath(in), "-o", tailwindPath(output)}
+
+	if !v.CssConfig() {
+		config := tailwindPath(o.Config)
+		if config == "" {
+			config = tailwindJsConfig()
+		}
+		if config != "" {
+			args = append(args, "-c", config)
+		}
+		content := o.Content
+		if content == nil && config == "" {
+			content = tailwindGoContent()
+		}
+		if len(content) > 0 {
+			globs := make([]string, len(content))
+			for i, c := range content {
+				globs[i] = filepath.ToSlash(tailwindPath(c))
+			}
+			if Config.TailwindScanClasses() {
+				globs = append(globs, filepath.ToSlash(tailwindPath(tailwindClassesPath())))
+			}
+			args = append(args, "--content", strings.Join(globs, ","))
+		} else if Config.TailwindScanClasses() {
+			// The content of the configuration file can't be extended.
+			warnTailwindClassesOnce.Do(func() {
+				log.Printf("Add %q to the content of %s to use the classes found in the Go files", tailwindClassesPath(), config)
+			})
+		}
+		if o.Postcss != "" {
+			args = append(args, "--postcss", tailwindPath(o.Postcss))
+		}
+	}
+	if o.Minify != nil && *o.Minify {
+		args = append(args, "--minify")
+	}
+	return append(args, o.ExtraArgs.Args()...), nil
+}
+
+// tailwindPath returns path, which is relative to the current directory, as a
+// path that Tailwind finds from tailwind_dir.
+func tailwindPath(path string) string {
+	if path == "" || Config.TailwindDir == "" {
+		return path
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	return abs
+}
+
+// writeTailwindInput writes the stylesheet that Tailwind v4 builds for input,
+// next to the output of source, and returns its path. Its name doesn't end
+// with .css so that it is not served.
+func writeTailwindInput(source, input string, o TailwindOptions) (string, error) {
+	w := filepath.Join(filepath.Dir(cssOutputPath(source)), "."+filepath.Base(source)+".tailwind")
+	dir, err := filepath.Abs(filepath.Dir(w))
+	if err != nil {
+		return "", err
+	}
+	// Paths in the stylesheet are relative to it.
+	rel := func(p string) string {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return filepath.ToSlash(p)
+		}
+		r, err := filepath.Rel(dir, abs)
+		if err != nil {
+			return filepath.ToSlash(abs)
+		}
+		r = filepath.ToSlash(r)
+		if !strings.HasPrefix(r, "../") {
+			r = "./" + r
+		}
+		return r
+	}
+	quote := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+	var b strings.Builder
+	b.WriteString("/* Generated by wasmserve from the [tailwind] options. */\n")
+	fmt.Fprintf(&b, "@import \"%s\";\n", quote.Replace(rel(input)))
+	if o.Config != "" {
+		fmt.Fprintf(&b, "@config \"%s\";\n", quote.Replace(rel(o.Config)))
+	}
+	content := o.Content
+	if content == nil {
+		content = tailwindGoContent()
+	}
+	for _, c := range content {
+		fmt.Fprintf(&b, "@source \"%s\";\n", quote.Replace(rel(c)))
+	}
+	if Config.TailwindScanClasses() {
+		fmt.Fprintf(&b, "@source \"%s\";\n", quote.Replace(rel(tailwindClassesPath())))
+	}
+
+	tmp, err := tempPath(w)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp)
+	if err := os.WriteFile(tmp, []byte(b.String()), 0644); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, w); err != nil {
+		return "", err
+	}
+	return w, nil
+}
+
+var tailwindGraph = struct {
+	sync.Mutex
+	g      *buildGraph
+	loaded bool
+}{}
+
+// setTailwindGraph sets the build graph whose Go files Tailwind scans for
+// classes.
+func setTailwindGraph(g *buildGraph) {
+	tailwindGraph.Lock()
+	defer tailwindGraph.Unlock()
+	tailwindGraph.g = g
+	tailwindGraph.loaded = true
+}
+
+// tailwindBuildGraph returns the build graph set by setTailwindGraph, or loads
+// it. It returns nil if go list fails.
+func tailwindBuildGraph() *buildGraph {
+	tailwindGraph.Lock()
+	defer tailwindGraph.Unlock()
+	if !tailwindGraph.loaded {
+		g, err := loadBuildGraph()
+		if err != nil {
+			log.Print(err)
+		}
+		tailwindGraph.g = g
+		tailwindGraph.loaded = true
+	}
+	return tailwindGraph.g
+}
+
+// tailwindGoContent returns the globs of the Go files of the local packages
+// in the build graph, which Tailwind scans for classes by default.
+func tailwindGoContent() []string {
+	g := tailwindBuildGraph()
+	if g == nil {
+		root, _ := filepath.Abs(Config.Root)
+		return []string{filepath.Join(root, "**", "*.go")}
+	}
+	return g.goGlobs()
+}
+
+// tailwindClassesPath is the file of the classes that ScanClasses finds in the
+// Go files. Tailwind scans it along with the content.
+func tailwindClassesPath() string {
+	return filepath.Join(Config.TmpDir, "tailwind-classes.txt")
+}
+
+// writeTailwindClasses writes the classes of the Go files of the build graph
+// to tailwindClassesPath. The file is not touched if the classes didn't
+// change, so that the tailwind --watch processes don't rebuild.
+func writeTailwindClasses() error {
+	if !Config.TailwindScanClasses() {
+		return nil
+	}
+	g := tailwindBuildGraph()
+	if g == nil {
+		return nil
+	}
+	classes, err := ScanClasses(g.goFiles())
+	if err != nil {
+		return err
+	}
+	content := []byte(strings.Join(classes, "\n") + "\n")
+	p := tailwindClassesPath()
+	if old, err := os.ReadFile(p); err == nil && bytes.Equal(old, content) {
+		return nil
+	}
+	tmp, err := tempPath(p)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	if err := os.WriteFile(tmp, content, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, p)
+}
+
+var warnTailwindClassesOnce sync.Once
+
+var tailwindCmd = &cobra.Command{
+	Use:   "tailwind",
+	Short: "Manage the standalone Tailwind executable",
+}
+
+var tailwindInstallCmd = &cobra.Command{
+	Use:   "install [version]",
+	Short: "Download tailwind_version, or the given version, to the cache",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := initConf(); err != nil {
+			log.Fatal(err)
+			return
+		}
+		version := Config.TailwindVersion
+		if len(args) > 0 {
+			version = args[0]
+		}
+		version, p, err := installTailwind(version, false)
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+		fmt.Printf("Tailwind %s: %s\n", version, p)
+	},
+}
+
+var tailwindUpdateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Download the latest Tailwind and pin tailwind_version to it",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := initConf(); err != nil {
+			log.Fatal(err)
+			return
+		}
+		version, p, err := installTailwind(TailwindLatest, false)
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+		fmt.Printf("Tailwind %s: %s\n", version, p)
+
+		pinned, _ := NormalizeTailwindVersion(Config.TailwindVersion)
+		if Config.TailwindVersion == "" || pinned == TailwindLatest || pinned == version {
+			return
+		}
+		updated, err := pinTailwindVersion(flagConf, version)
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+		if updated {
+			fmt.Printf("Updated tailwind_version in %s from %s to %s\n", flagConf, pinned, version)
+		}
+	},
+}
+
+var tailwindWhichCmd = &cobra.Command{
+	Use:   "which",
+	Short: "Print the path of the Tailwind executable",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := initConf(); err != nil {
+			log.Fatal(err)
+			return
+		}
+		if Config.TailwindVersion == "" {
+			c, err := tailwindCommand(context.Background())
+			if err != nil {
+				log.Fatal(err)
+				return
+			}
+			p := c.Path
+			if !filepath.IsAbs(p) {
+				// A relative path is relative to tailwind_dir.
+				p = filepath.Join(Config.TailwindDir, p)
+			}
+			if _, err := os.Stat(p); err != nil {
+				log.Fatalf("%s: %v", commandLine(c), err)
+				return
+			}
+			fmt.Println(p)
+			return
+		}
+
+		r, err := Config.TailwindRelease()
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+		name, err := TailwindExecutableName(runtime.GOOS, runtime.GOARCH)
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+		version, _ := NormalizeTailwindVersion(Config.TailwindVersion)
+		if version == TailwindLatest {
+			if version, err = latestTailwind(r, name, true); err != nil {
+				log.Fatal(err)
+				return
+			}
+		}
+		p := r.Path(version, name)
+		if _, err := os.Stat(p); err != nil {
+			log.Fatalf("Tailwind %s is not installed. Run `wasmserve tailwind install`", version)
+			return
+		}
+		fmt.Println(p)
+	},
+}
+
+var tailwindVersionLine = regexp.MustCompile(`(?m)^(\s*tailwind_version\s*=\s*)("[^"]*"|'[^']*')`)
+
+// pinTailwindVersion rewrites the tailwind_version line of the config file,
+// keeping the rest of the file as it is. It reports whether the line was found.
+func pinTailwindVersion(path, version string) (bool, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	if !tailwindVersionLine.Match(b) {
+		return false, nil
+	}
+	b = tailwindVersionLine.ReplaceAll(b, []byte(`${1}"`+strings.ReplaceAll(version, "$", "$$")+`"`))
+	return true, os.WriteFile(path, b, 0644)
+}
@@ -0,0 +1,237 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	. "github.com/hajimehoshi/wasmserve/pkg"
+)
+
+const (
+	tailwindRestartDelay    = time.Second
+	tailwindMaxRestartDelay = 30 * time.Second
+	// tailwindStopTimeout is how long a process may take to exit after its
+	// stdin is closed, before it is killed.
+	tailwindStopTimeout = 3 * time.Second
+)
+
+// tailwindWatchers keeps a `tailwindcss --watch` process running for every css
+// input, so that css files are rebuilt without the startup cost of Tailwind.
+type tailwindWatchers struct {
+	mu    sync.Mutex
+	procs map[string]context.CancelFunc
+	// outputs is the set of the cleaned output paths of the processes, so
+	// that the other builds writing to tmp_dir don't reload the browsers.
+	outputs map[string]bool
+	wg      sync.WaitGroup
+	fs      *fsnotify.Watcher
+}
+
+func newTailwindWatchers(ctx context.Context) (*tailwindWatchers, error) {
+	if err := os.MkdirAll(Config.TmpDir, 0755); err != nil {
+		return nil, err
+	}
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := w.Add(Config.TmpDir); err != nil {
+		w.Close()
+		return nil, err
+	}
+	t := &tailwindWatchers{
+		procs:   map[string]context.CancelFunc{},
+		outputs: map[string]bool{},
+		fs:      w,
+	}
+	go t.watchOutputs(ctx)
+	return t, nil
+}
+
+// sync starts the processes of new inputs and stops the ones of removed inputs.
+func (t *tailwindWatchers) sync(ctx context.Context, inputs []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	current := map[string]bool{}
+	for _, in := range inputs {
+		current[in] = true
+		if _, ok := t.procs[in]; ok {
+			continue
+		}
+		// The outputs mirror the directories of the inputs, which are not
+		// watched by watching tmp_dir.
+		if dir := filepath.Dir(cssOutputPath(in)); dir != filepath.Clean(Config.TmpDir) {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				log.Print(err)
+			} else if err := t.fs.Add(dir); err != nil {
+				log.Print(err)
+			}
+		}
+		pctx, cancel := context.WithCancel(ctx)
+		t.procs[in] = cancel
+		t.outputs[filepath.Clean(cssOutputPath(in))] = true
+		cssFiles.Add(&CssPath{Input: in, Output: cssOutputPath(in)})
+		t.wg.Add(1)
+		go func(in string) {
+			defer t.wg.Done()
+			t.supervise(pctx, in)
+		}(in)
+	}
+	for in, cancel := range t.procs {
+		if !current[in] {
+			cancel()
+			delete(t.procs, in)
+			delete(t.outputs, filepath.Clean(cssOutputPath(in)))
+		}
+	}
+}
+
+// stop stops every process and waits for them to exit.
+func (t *tailwindWatchers) stop() {
+	t.mu.Lock()
+	for in, cancel := range t.procs {
+		cancel()
+		delete(t.procs, in)
+		delete(t.outputs, filepath.Clean(cssOutputPath(in)))
+	}
+	t.mu.Unlock()
+	t.wg.Wait()
+	t.fs.Close()
+}
+
+// supervise runs the process of input, and restarts it when it exits until ctx
+// is done.
+func (t *tailwindWatchers) supervise(ctx context.Context, input string) {
+	delay := tailwindRestartDelay
+	for {
+		start := time.Now()
+		err := t.run(ctx, input)
+		if ctx.Err() != nil {
+			return
+		}
+		// A process that ran for a while is not crashing in a loop.
+		if time.Since(start) > tailwindMaxRestartDelay {
+			delay = tailwindRestartDelay
+		}
+		log.Printf("tailwind %s exited: %v. Restarting in %s", input, err, delay)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return
+		}
+		delay *= 2
+		if delay > tailwindMaxRestartDelay {
+			delay = tailwindMaxRestartDelay
+		}
+	}
+}
+
+// run runs one `tailwindcss --watch` process for input until it exits or ctx is
+// done. Tailwind exits when its stdin is closed, so the process is stopped by
+// closing its stdin, and only killed if it doesn't exit in time.
+func (t *tailwindWatchers) run(ctx context.Context, input string) error {
+	args, err := tailwindArgs(tailwindVersion(ctx), input, input, cssOutputPath(input))
+	if err != nil {
+		return err
+	}
+	// The process is stopped by closing stdin rather than by ctx.
+	c, err := tailwindCommand(context.Background(), append(args, "--watch")...)
+	if err != nil {
+		return err
+	}
+	stdin, err := c.StdinPipe()
+	if err != nil {
+		return err
+	}
+	pr, pw := io.Pipe()
+	c.Stdout = pw
+	c.Stderr = pw
+	if err := c.Start(); err != nil {
+		return fmt.Errorf("%s: %v", commandLine(c), err)
+	}
+	go forwardTailwindOutput(input, pr)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Wait()
+		pw.Close()
+	}()
+
+	select {
+	case err := <-done:
+		stdin.Close()
+		return err
+	case <-ctx.Done():
+		stdin.Close()
+		select {
+		case err := <-done:
+			return err
+		case <-time.After(tailwindStopTimeout):
+			c.Process.Kill()
+			return <-done
+		}
+	}
+}
+
+// forwardTailwindOutput logs the output of a Tailwind process, and shows the
+// errors in the browsers.
+func forwardTailwindOutput(input string, r io.Reader) {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		l := strings.TrimSpace(s.Text())
+		if l == "" {
+			continue
+		}
+		log.Printf("tailwind %s: %s", input, l)
+		if strings.Contains(strings.ToLower(l), "error") {
+			events.publish(eventBuildError, "tailwind "+input+": "+l)
+		}
+	}
+}
+
+// isOutput reports whether name is the output of a process.
+func (t *tailwindWatchers) isOutput(name string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.outputs[filepath.Clean(name)]
+}
+
+// watchOutputs reloads the browsers when the processes write their outputs.
+// Tailwind writes a file in several steps, so the reload waits until the
+// writes stop. The other css outputs in tmp_dir are written by the builds,
+// which reload the browsers themselves.
+func (t *tailwindWatchers) watchOutputs(ctx context.Context) {
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	for {
+		select {
+		case e, ok := <-t.fs.Events:
+			if !ok {
+				return
+			}
+			if e.Op&(fsnotify.Write|fsnotify.Create) != 0 && t.isOutput(e.Name) {
+				timer.Reset(Config.WatchDelay())
+			}
+		case err, ok := <-t.fs.Errors:
+			if !ok {
+				return
+			}
+			log.Print(err)
+		case <-timer.C:
+			events.publish(eventReload, "")
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,332 @@
+package cmd
+
+import (
+	"context"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	ignore "github.com/sabhiram/go-gitignore"
+
+	. "github.com/hajimehoshi/wasmserve/pkg"
+)
+
+// fileWatcher reports batches of changed files under Config.Root.
+type fileWatcher struct {
+	fs      *fsnotify.Watcher
+	root    string
+	include []string
+	exclude []string
+	regexes []*regexp.Regexp
+	// ignores holds the compiled .gitignore files, keyed by the slash-separated
+	// directory relative to root.
+	ignores map[string]*ignore.GitIgnore
+	// graph decides which Go files are watched. If it is nil, Go files are
+	// watched like the other files.
+	graph *buildGraph
+}
+
+func newFileWatcher() (*fileWatcher, error) {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	fw := &fileWatcher{
+		fs:      w,
+		root:    Config.Root,
+		include: Config.WatchInclude(),
+		exclude: Config.WatchExclude(),
+		ignores: map[string]*ignore.GitIgnore{},
+	}
+	for _, r := range Config.Build.ExcludeRegex {
+		re, err := regexp.Compile(r)
+		if err != nil {
+			w.Close()
+			return nil, err
+		}
+		fw.regexes = append(fw.regexes, re)
+	}
+	if err := fw.addDir(fw.root); err != nil {
+		w.Close()
+		return nil, err
+	}
+	fw.reloadGraph()
+	return fw, nil
+}
+
+// isGoInput reports whether name is a Go source or module file.
+func isGoInput(name string) bool {
+	return isGoModFile(name) || filepath.Ext(name) == ".go"
+}
+
+// reloadGraph asks go list for the files of the build graph, and watches the
+// directories of the local modules outside the root. On failure, the previous
+// graph is kept.
+func (fw *fileWatcher) reloadGraph() {
+	g, err := loadBuildGraph()
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	fw.graph = g
+	setTailwindGraph(g)
+	for dir := range g.dirs {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			continue
+		}
+		if _, ok := fw.rel(abs); ok {
+			continue
+		}
+		if err := fw.fs.Add(abs); err != nil {
+			log.Print(err)
+		}
+	}
+}
+
+func (fw *fileWatcher) Close() error {
+	return fw.fs.Close()
+}
+
+// rel returns the slash-separated path of name relative to the root, or false
+// if name is outside the root.
+func (fw *fileWatcher) rel(name string) (string, bool) {
+	root := fw.root
+	if filepath.IsAbs(name) {
+		var err error
+		if root, err = filepath.Abs(root); err != nil {
+			return "", false
+		}
+	}
+	r, err := filepath.Rel(root, name)
+	if err != nil || r == ".." || strings.HasPrefix(r, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(r), true
+}
+
+// gitignored reports whether rel is ignored by a .gitignore file in one of its
+// parent directories.
+func (fw *fileWatcher) gitignored(rel string, isDir bool) bool {
+	if !Config.WatchGitignore() {
+		return false
+	}
+	for dir := path.Dir(rel); ; dir = path.Dir(dir) {
+		if gi, ok := fw.ignores[dir]; ok {
+			p := rel
+			if dir != "." {
+				p = strings.TrimPrefix(rel, dir+"/")
+			}
+			if gi.MatchesPath(p) || (isDir && gi.MatchesPath(p+"/")) {
+				return true
+			}
+		}
+		if dir == "." {
+			return false
+		}
+	}
+}
+
+func (fw *fileWatcher) excluded(rel string, isDir bool) bool {
+	if rel == "." {
+		return false
+	}
+	if isDir && strings.HasPrefix(path.Base(rel), ".") {
+		return true
+	}
+	for _, g := range fw.exclude {
+		if MatchGlob(g, rel) {
+			return true
+		}
+	}
+	for _, re := range fw.regexes {
+		if re.MatchString(rel) {
+			return true
+		}
+	}
+	return fw.gitignored(rel, isDir)
+}
+
+// watched reports whether a change of the file name triggers a build. Go files
+// are watched when they are in the build graph, even outside the root.
+func (fw *fileWatcher) watched(name string) bool {
+	rel, inRoot := fw.rel(name)
+	if inRoot && fw.excluded(rel, false) {
+		return false
+	}
+	if fw.graph != nil && isGoInput(name) {
+		return fw.graph.mayContain(name)
+	}
+	if !inRoot {
+		return false
+	}
+	for _, g := range fw.include {
+		if MatchGlob(g, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+func (fw *fileWatcher) loadGitignore(dir string) {
+	rel, ok := fw.rel(dir)
+	if !ok {
+		return
+	}
+	gi, err := ignore.CompileIgnoreFile(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		delete(fw.ignores, rel)
+		return
+	}
+	fw.ignores[rel] = gi
+}
+
+// addDir watches dir and its subdirectories that are not excluded.
+func (fw *fileWatcher) addDir(dir string) error {
+	return filepath.Walk(dir, func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		rel, ok := fw.rel(name)
+		if !ok || fw.excluded(rel, true) {
+			return filepath.SkipDir
+		}
+		fw.loadGitignore(name)
+		return fw.fs.Add(name)
+	})
+}
+
+// handle processes an event and returns the changed files that trigger a
+// build. A removed directory is returned with a trailing separator, as the
+// files that were in it are unknown.
+func (fw *fileWatcher) handle(e fsnotify.Event) []string {
+	if e.Name == "" {
+		return nil
+	}
+	e.Name = filepath.Clean(e.Name)
+	if filepath.Base(e.Name) == ".gitignore" {
+		fw.loadGitignore(filepath.Dir(e.Name))
+		return nil
+	}
+	switch {
+	case e.Op&fsnotify.Create != 0:
+		if fi, err := os.Stat(e.Name); err == nil && fi.IsDir() {
+			// A new or renamed directory. The files moved with it don't get
+			// their own events.
+			if err := fw.addDir(e.Name); err != nil {
+				log.Print(err)
+			}
+			return fw.watchedFiles(e.Name)
+		}
+	case e.Op&(fsnotify.Rename|fsnotify.Remove) != 0:
+		// The old name of a directory must not be watched anymore. Remove
+		// fails for files and unwatched directories.
+		if err := fw.fs.Remove(e.Name); err == nil {
+			return []string{e.Name + string(filepath.Separator)}
+		}
+	case e.Op == fsnotify.Chmod:
+		return nil
+	}
+	if fw.watched(e.Name) {
+		return []string{e.Name}
+	}
+	return nil
+}
+
+func (fw *fileWatcher) watchedFiles(dir string) []string {
+	var files []string
+	filepath.Walk(dir, func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			if rel, ok := fw.rel(name); !ok || fw.excluded(rel, true) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if fw.watched(name) {
+			files = append(files, name)
+		}
+		return nil
+	})
+	return files
+}
+
+// flush returns the pending changes. If Go files changed, the build graph is
+// reloaded first, and the Go files that are in neither the old nor the new
+// graph are dropped.
+func (fw *fileWatcher) flush(pending map[string]struct{}) []string {
+	old := fw.graph
+	if old != nil {
+		for f := range pending {
+			if isGoInput(f) {
+				fw.reloadGraph()
+				break
+			}
+		}
+	}
+
+	files := make([]string, 0, len(pending))
+	for f := range pending {
+		if old != nil && isGoInput(f) && !old.contains(f) && !fw.graph.contains(f) {
+			continue
+		}
+		files = append(files, f)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// watch sends the files changed since the last batch to out. Changes are
+// batched until no file has changed for Config.WatchDelay().
+func (fw *fileWatcher) watch(ctx context.Context, out chan<- []string) {
+	delay := Config.WatchDelay()
+	pending := map[string]struct{}{}
+	timer := time.NewTimer(delay)
+	timer.Stop()
+
+	for {
+		select {
+		case e, ok := <-fw.fs.Events:
+			if !ok {
+				return
+			}
+			files := fw.handle(e)
+			if len(files) == 0 {
+				continue
+			}
+			for _, f := range files {
+				pending[f] = struct{}{}
+			}
+			timer.Reset(delay)
+		case err, ok := <-fw.fs.Errors:
+			if !ok {
+				return
+			}
+			log.Print(err)
+		case <-timer.C:
+			files := fw.flush(pending)
+			pending = map[string]struct{}{}
+			if len(files) == 0 {
+				continue
+			}
+			select {
+			case out <- files:
+			case <-ctx.Done():
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -4,9 +4,12 @@ go 1.16
 
 require (
 	github.com/AlecAivazis/survey/v2 v2.3.4
-	github.com/cosmtrek/air v1.29.0
-	github.com/fsnotify/fsnotify v1.5.4 // indirect
+	github.com/andybalholm/brotli v1.1.1
+	github.com/evanw/esbuild v0.28.2
+	github.com/fsnotify/fsnotify v1.5.4
+	github.com/mattn/go-colorable v0.1.8 // indirect
 	github.com/pelletier/go-toml v1.9.5
+	github.com/sabhiram/go-gitignore v0.0.0-20210923224102-525f6e181f06
 	github.com/spf13/cobra v1.4.0
 	github.com/stretchr/testify v1.7.1
 )
@@ -0,0 +1,116 @@
+package pkg
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SizeBudget is a [[size_budget]] rule: the largest size of each output
+// matching Files, and of its gzip and brotli compressed forms.
+type SizeBudget struct {
+	// Files is the glob of the URL paths of the outputs, such as "main.wasm"
+	// or "**/*.css".
+	Files string `toml:"files"`
+	// Max is the largest size of a file, such as "6MB".
+	Max string `toml:"max,omitempty"`
+	// MaxGzip is the largest size of a file compressed with gzip.
+	MaxGzip string `toml:"max_gzip,omitempty"`
+	// MaxBrotli is the largest size of a file compressed with brotli.
+	MaxBrotli string `toml:"max_brotli,omitempty"`
+}
+
+// Encodings of the sizes checked by the budgets.
+const (
+	BudgetRaw    = ""
+	BudgetGzip   = "gzip"
+	BudgetBrotli = "brotli"
+)
+
+// BudgetCheck is the check of an output against one of the limits of a
+// budget.
+type BudgetCheck struct {
+	// File is the URL path of the output.
+	File string
+	// Encoding is BudgetRaw, BudgetGzip or BudgetBrotli.
+	Encoding string
+	Size     int64
+	Limit    int64
+}
+
+// Over reports whether the output is larger than its budget.
+func (c BudgetCheck) Over() bool {
+	return c.Size > c.Limit
+}
+
+// Name returns the output with its encoding, such as "main.wasm (gzip)".
+func (c BudgetCheck) Name() string {
+	if c.Encoding == BudgetRaw {
+		return c.File
+	}
+	return c.File + " (" + c.Encoding + ")"
+}
+
+func (c BudgetCheck) String() string {
+	if c.Over() {
+		return fmt.Sprintf("%s is %s, over its budget of %s by %s", c.Name(), FormatSize(c.Size), FormatSize(c.Limit), FormatSize(c.Size-c.Limit))
+	}
+	return fmt.Sprintf("%s is %s, within its budget of %s", c.Name(), FormatSize(c.Size), FormatSize(c.Limit))
+}
+
+func validateSizeBudgets(budgets []SizeBudget) error {
+	for i, b := range budgets {
+		if b.Files == "" {
+			return fmt.Errorf("size_budget[%d]: files is empty", i)
+		}
+		if b.Max == "" && b.MaxGzip == "" && b.MaxBrotli == "" {
+			return fmt.Errorf("size_budget[%d]: set max, max_gzip or max_brotli", i)
+		}
+		for _, s := range []string{b.Max, b.MaxGzip, b.MaxBrotli} {
+			if s == "" {
+				continue
+			}
+			if _, err := ParseSize(s); err != nil {
+				return fmt.Errorf("size_budget[%d]: %v", i, err)
+			}
+		}
+	}
+	return nil
+}
+
+// CheckSizeBudgets checks the outputs, by their URL paths, against the
+// budgets. size returns the size of an output in an encoding. The checks are
+// sorted by output.
+func CheckSizeBudgets(budgets []SizeBudget, outputs []string, size func(name, encoding string) (int64, error)) ([]BudgetCheck, error) {
+	sorted := append([]string{}, outputs...)
+	sort.Strings(sorted)
+	var checks []BudgetCheck
+	for _, name := range sorted {
+		for _, b := range budgets {
+			if !MatchGlob(strings.TrimPrefix(b.Files, "/"), strings.TrimPrefix(name, "/")) {
+				continue
+			}
+			limits := []struct {
+				encoding string
+				max      string
+			}{
+				{BudgetRaw, b.Max},
+				{BudgetGzip, b.MaxGzip},
+				{BudgetBrotli, b.MaxBrotli},
+			}
+			for _, l := range limits {
+				if l.max == "" {
+					continue
+				}
+				// The limits are validated by ReadConfig.
+				limit, _ := ParseSize(l.max)
+				n, err := size(name, l.encoding)
+				if err != nil {
+					return nil, err
+				}
+				checks = append(checks, BudgetCheck{File: name, Encoding: l.encoding, Size: n, Limit: limit})
+			}
+		}
+	}
+	return checks, nil
+}
@@ -0,0 +1,75 @@
+package pkg
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSizeBudgetsConfig(t *testing.T) {
+	conf, err := ReadConfig(writeConfig(t, `
+[[size_budget]]
+files = "main.wasm"
+max = "6MB"
+max_gzip = "1.5MB"
+
+[[size_budget]]
+files = "**/*.css"
+max = "50KB"
+`))
+	assert.Nil(t, err)
+	assert.Equal(t, []SizeBudget{
+		{Files: "main.wasm", Max: "6MB", MaxGzip: "1.5MB"},
+		{Files: "**/*.css", Max: "50KB"},
+	}, conf.SizeBudgets)
+
+	for _, c := range []string{
+		"[[size_budget]]\nmax = \"6MB\"",
+		"[[size_budget]]\nfiles = \"main.wasm\"",
+		"[[size_budget]]\nfiles = \"main.wasm\"\nmax_gzip = \"big\"",
+	} {
+		_, err := ReadConfig(writeConfig(t, c))
+		assert.NotNil(t, err, c)
+	}
+}
+
+func TestCheckSizeBudgets(t *testing.T) {
+	budgets := []SizeBudget{
+		{Files: "main.wasm", Max: "6MB", MaxGzip: "1.5MB"},
+		{Files: "**/*.css", Max: "50KB"},
+	}
+	sizes := map[string]int64{
+		"main.wasm":         5 << 20,
+		"main.wasm gzip":    2 << 20,
+		"css/main.css":      60 << 10,
+		"styles.css":        10 << 10,
+		"js/worklet.js":     1 << 30,
+		"css/main.css gzip": 1,
+	}
+	size := func(name, encoding string) (int64, error) {
+		if encoding != BudgetRaw {
+			name += " " + encoding
+		}
+		return sizes[name], nil
+	}
+	checks, err := CheckSizeBudgets(budgets, []string{"styles.css", "main.wasm", "js/worklet.js", "css/main.css"}, size)
+	assert.Nil(t, err)
+	assert.Equal(t, []BudgetCheck{
+		{File: "css/main.css", Size: 60 << 10, Limit: 50 << 10},
+		{File: "main.wasm", Size: 5 << 20, Limit: 6 << 20},
+		{File: "main.wasm", Encoding: BudgetGzip, Size: 2 << 20, Limit: 3 << 19},
+		{File: "styles.css", Size: 10 << 10, Limit: 50 << 10},
+	}, checks)
+
+	assert.True(t, checks[0].Over())
+	assert.Equal(t, "css/main.css is 60.0KB, over its budget of 50.0KB by 10.0KB", checks[0].String())
+	assert.False(t, checks[1].Over())
+	assert.Equal(t, "main.wasm (gzip)", checks[2].Name())
+	assert.True(t, checks[2].Over())
+
+	_, err = CheckSizeBudgets(budgets, []string{"main.wasm"}, func(string, string) (int64, error) {
+		return 0, errors.New("no file")
+	})
+	assert.NotNil(t, err)
+}
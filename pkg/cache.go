@@ -0,0 +1,218 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Cache stores build artifacts by the hash of their inputs. When the cache
+// grows over MaxSize, the least recently used artifacts are evicted.
+//
+// The artifacts are stored in the entries directory of Dir, and are named by
+// their keys: a hex SHA-256 hash with an optional extension. The cache only
+// ever removes files with such names, so Dir can be shared with other files.
+type Cache struct {
+	Dir     string
+	MaxSize int64
+}
+
+// CacheStats describes the contents of a Cache.
+type CacheStats struct {
+	Entries int
+	Size    int64
+}
+
+// DefaultCacheDir returns the per-user cache directory of wasmserve.
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "wasmserve"), nil
+}
+
+// cacheEntriesDir is the directory of Cache.Dir that holds the artifacts.
+const cacheEntriesDir = "entries"
+
+var cacheKeyRe = regexp.MustCompile(`^[0-9a-f]{64}(\.[0-9A-Za-z]+)?$`)
+
+// IsCacheKey reports whether key is a valid key of a Cache: a hex SHA-256
+// hash, optionally followed by an extension such as ".wasm".
+func IsCacheKey(key string) bool {
+	return cacheKeyRe.MatchString(key)
+}
+
+func (c *Cache) dir() string {
+	return filepath.Join(c.Dir, cacheEntriesDir)
+}
+
+func (c *Cache) path(key string) (string, error) {
+	if !IsCacheKey(key) {
+		return "", fmt.Errorf("invalid cache key: %q", key)
+	}
+	return filepath.Join(c.dir(), key), nil
+}
+
+// copyFile copies src to a temporary file next to dst and renames it to dst.
+func copyFile(dst, src string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	out, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(out.Name())
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Chmod(0644); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Rename(out.Name(), dst)
+}
+
+// Get copies the artifact of key to dst. It reports false if there is no such
+// artifact.
+func (c *Cache) Get(key, dst string) (bool, error) {
+	p, err := c.path(key)
+	if err != nil {
+		return false, err
+	}
+	if err := copyFile(dst, p); errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	// The modification time is used as the last access time for eviction.
+	now := time.Now()
+	if err := os.Chtimes(p, now, now); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Put stores a copy of src as the artifact of key, and evicts old artifacts
+// if needed.
+func (c *Cache) Put(key, src string) error {
+	p, err := c.path(key)
+	if err != nil {
+		return err
+	}
+	if err := copyFile(p, src); err != nil {
+		return err
+	}
+	return c.Trim()
+}
+
+func (c *Cache) entries() ([]fs.FileInfo, error) {
+	des, err := os.ReadDir(c.dir())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var infos []fs.FileInfo
+	for _, de := range des {
+		if !de.Type().IsRegular() || !IsCacheKey(de.Name()) {
+			continue
+		}
+		info, err := de.Info()
+		if err != nil {
+			continue
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// Trim evicts the least recently used artifacts until the cache is not larger
+// than MaxSize. A MaxSize of 0 means no limit.
+func (c *Cache) Trim() error {
+	if c.MaxSize <= 0 {
+		return nil
+	}
+	infos, err := c.entries()
+	if err != nil {
+		return err
+	}
+	var size int64
+	for _, i := range infos {
+		size += i.Size()
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].ModTime().Before(infos[j].ModTime())
+	})
+	for _, i := range infos {
+		if size <= c.MaxSize {
+			break
+		}
+		if err := os.Remove(filepath.Join(c.dir(), i.Name())); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		size -= i.Size()
+	}
+	return nil
+}
+
+// Stats returns the number and total size of the artifacts.
+func (c *Cache) Stats() (CacheStats, error) {
+	infos, err := c.entries()
+	if err != nil {
+		return CacheStats{}, err
+	}
+	var s CacheStats
+	for _, i := range infos {
+		s.Entries++
+		s.Size += i.Size()
+	}
+	return s, nil
+}
+
+// Clean removes every artifact, and the temporary files of the artifacts
+// being stored. The other files are kept.
+func (c *Cache) Clean() error {
+	des, err := os.ReadDir(c.dir())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, de := range des {
+		name := de.Name()
+		// copyFile names the temporary files ".<key>.<random>".
+		if i := strings.LastIndex(name, "."); strings.HasPrefix(name, ".") && i > 0 {
+			name = name[1:i]
+		}
+		if !de.Type().IsRegular() || !IsCacheKey(name) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(c.dir(), de.Name())); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	// The directory is kept if it has other files.
+	os.Remove(c.dir())
+	return nil
+}
@@ -0,0 +1,71 @@
+package pkg
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCache(t *testing.T) {
+	dir := t.TempDir()
+	c := &Cache{Dir: filepath.Join(dir, "cache"), MaxSize: 10}
+	a := strings.Repeat("a", 64) + ".wasm"
+	b := strings.Repeat("b", 64) + ".wasm"
+
+	// The files that are not artifacts are never removed.
+	other := filepath.Join(c.Dir, "entries", "other")
+	assert.Nil(t, os.MkdirAll(filepath.Dir(other), 0755))
+	assert.Nil(t, os.WriteFile(other, []byte("0123456789"), 0644))
+
+	src := filepath.Join(dir, "src")
+	dst := filepath.Join(dir, "dst")
+	assert.Nil(t, os.WriteFile(src, []byte("123456"), 0644))
+
+	ok, err := c.Get(a, dst)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	assert.Nil(t, c.Put(a, src))
+	ok, err = c.Get(a, dst)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	data, err := os.ReadFile(dst)
+	assert.Nil(t, err)
+	assert.Equal(t, "123456", string(data))
+
+	// Make a the least recently used entry, and evict it by adding b.
+	old := time.Now().Add(-time.Hour)
+	assert.Nil(t, os.Chtimes(filepath.Join(c.Dir, "entries", a), old, old))
+	assert.Nil(t, c.Put(b, src))
+	ok, err = c.Get(a, dst)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	s, err := c.Stats()
+	assert.Nil(t, err)
+	assert.Equal(t, CacheStats{Entries: 1, Size: 6}, s)
+
+	assert.Nil(t, c.Clean())
+	s, err = c.Stats()
+	assert.Nil(t, err)
+	assert.Equal(t, CacheStats{}, s)
+	_, err = os.Stat(other)
+	assert.Nil(t, err)
+
+	assert.NotNil(t, c.Put("../a", src))
+	_, err = c.Get("a", dst)
+	assert.NotNil(t, err)
+}
+
+func TestIsCacheKey(t *testing.T) {
+	assert.True(t, IsCacheKey(strings.Repeat("0", 64)))
+	assert.True(t, IsCacheKey(strings.Repeat("f", 64)+".wasm"))
+	assert.False(t, IsCacheKey(strings.Repeat("F", 64)))
+	assert.False(t, IsCacheKey(strings.Repeat("0", 63)))
+	assert.False(t, IsCacheKey("main.go"))
+	assert.False(t, IsCacheKey("."+strings.Repeat("0", 64)))
+}
@@ -0,0 +1,238 @@
+package pkg
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ClassesDirective is the comment that lists classes for Tailwind, such as
+//
+//	//wasmserve:classes bg-red-500 bg-green-500
+const ClassesDirective = "//wasmserve:classes"
+
+// classUnknown stands for the parts of a class string that can't be known
+// without running the program. The classes that contain it are dropped.
+const classUnknown = "\x00"
+
+// maxClassValues limits the combinations of the constants in a class string.
+const maxClassValues = 256
+
+var (
+	classKeys = map[string]bool{"class": true, "className": true, "classList": true}
+	// classFields are the struct fields that hold classes in HTML libraries.
+	classFields  = map[string]bool{"Class": true, "ClassName": true, "Classes": true}
+	formatVerbRe = regexp.MustCompile(`%[-+# 0-9.*\[\]]*[a-zA-Z%]`)
+)
+
+// ScanClasses returns the classes that the Go files filenames pass to class,
+// className and classList in syscall/js calls, such as
+//
+//	p.Set("class", "font-sans "+variant)
+//	p.Call("setAttribute", "class", "text-2xl")
+//	p.Get("classList").Call("add", "hidden")
+//
+// and the classes listed in ClassesDirective comments. String literals and
+// constants are followed through + and fmt.Sprintf. Parts that are only known
+// at run time, such as variables, are skipped.
+func ScanClasses(filenames []string) ([]string, error) {
+	fset := token.NewFileSet()
+	byDir := map[string][]*ast.File{}
+	var dirs []string
+	for _, name := range filenames {
+		f, err := parser.ParseFile(fset, name, nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		dir := filepath.Dir(name)
+		if _, ok := byDir[dir]; !ok {
+			dirs = append(dirs, dir)
+		}
+		byDir[dir] = append(byDir[dir], f)
+	}
+
+	classes := map[string]bool{}
+	for _, dir := range dirs {
+		s := &classScanner{consts: map[string][]ast.Expr{}, evaluating: map[string]bool{}}
+		for _, f := range byDir[dir] {
+			s.addConsts(f)
+		}
+		for _, f := range byDir[dir] {
+			for _, v := range s.scan(f) {
+				for _, c := range strings.Fields(v) {
+					if isClass(c) {
+						classes[c] = true
+					}
+				}
+			}
+		}
+	}
+
+	list := make([]string, 0, len(classes))
+	for c := range classes {
+		list = append(list, c)
+	}
+	sort.Strings(list)
+	return list, nil
+}
+
+func isClass(c string) bool {
+	return !strings.ContainsAny(c, classUnknown+`"<>{}\`)
+}
+
+// classScanner scans the files of one package.
+type classScanner struct {
+	// consts holds the values of the constants by name. Constants of
+	// different scopes with the same name are all kept, since any of them
+	// might be the one used.
+	consts     map[string][]ast.Expr
+	evaluating map[string]bool
+}
+
+func (s *classScanner) addConsts(f *ast.File) {
+	ast.Inspect(f, func(n ast.Node) bool {
+		d, ok := n.(*ast.GenDecl)
+		if !ok || d.Tok != token.CONST {
+			return true
+		}
+		for _, spec := range d.Specs {
+			vs := spec.(*ast.ValueSpec)
+			for i, name := range vs.Names {
+				if i < len(vs.Values) {
+					s.consts[name.Name] = append(s.consts[name.Name], vs.Values[i])
+				}
+			}
+		}
+		return true
+	})
+}
+
+// scan returns the class strings of f.
+func (s *classScanner) scan(f *ast.File) []string {
+	var values []string
+	for _, g := range f.Comments {
+		for _, c := range g.List {
+			if strings.HasPrefix(c.Text, ClassesDirective) {
+				values = append(values, strings.TrimPrefix(c.Text, ClassesDirective))
+			}
+		}
+	}
+
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.CallExpr:
+			// p.Set("class", v) and p.Call("setAttribute", "class", v)
+			for i, a := range n.Args {
+				if classKeys[stringLit(a)] {
+					for _, v := range n.Args[i+1:] {
+						values = append(values, s.eval(v)...)
+					}
+					break
+				}
+			}
+			// p.Get("classList").Call("add", v)
+			if sel, ok := n.Fun.(*ast.SelectorExpr); ok && sel.Sel.Name == "Call" && len(n.Args) > 1 {
+				if recv, ok := sel.X.(*ast.CallExpr); ok && len(recv.Args) == 1 && stringLit(recv.Args[0]) == "classList" {
+					for _, v := range n.Args[1:] {
+						values = append(values, s.eval(v)...)
+					}
+				}
+			}
+		case *ast.KeyValueExpr:
+			// map[string]string{"class": v} and Element{Class: v}
+			if id, ok := n.Key.(*ast.Ident); (ok && classFields[id.Name]) || classKeys[stringLit(n.Key)] {
+				values = append(values, s.eval(n.Value)...)
+			}
+		}
+		return true
+	})
+	return values
+}
+
+func stringLit(e ast.Expr) string {
+	lit, ok := e.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return ""
+	}
+	v, err := strconv.Unquote(lit.Value)
+	if err != nil {
+		return ""
+	}
+	return v
+}
+
+// eval returns the strings that e might be.
+func (s *classScanner) eval(e ast.Expr) []string {
+	if vs := s.evalExpr(e); len(vs) > 0 {
+		return vs
+	}
+	return []string{classUnknown}
+}
+
+func (s *classScanner) evalExpr(e ast.Expr) []string {
+	switch e := e.(type) {
+	case *ast.BasicLit:
+		if e.Kind == token.STRING {
+			return []string{stringLit(e)}
+		}
+	case *ast.ParenExpr:
+		return s.evalExpr(e.X)
+	case *ast.Ident:
+		if s.evaluating[e.Name] || len(s.consts[e.Name]) == 0 {
+			break
+		}
+		s.evaluating[e.Name] = true
+		defer delete(s.evaluating, e.Name)
+		var vs []string
+		for _, c := range s.consts[e.Name] {
+			vs = append(vs, s.eval(c)...)
+		}
+		return vs
+	case *ast.BinaryExpr:
+		if e.Op != token.ADD {
+			break
+		}
+		var vs []string
+		for _, x := range s.eval(e.X) {
+			for _, y := range s.eval(e.Y) {
+				if len(vs) == maxClassValues {
+					return vs
+				}
+				vs = append(vs, x+y)
+			}
+		}
+		return vs
+	case *ast.CallExpr:
+		if sel, ok := e.Fun.(*ast.SelectorExpr); ok && sel.Sel.Name == "Sprintf" && len(e.Args) > 0 {
+			var vs []string
+			for _, f := range s.eval(e.Args[0]) {
+				vs = append(vs, formatVerbRe.ReplaceAllString(f, classUnknown))
+			}
+			return vs
+		}
+		// Helpers such as strings.Join or cls("a", "b") return some of
+		// their arguments.
+		var vs []string
+		for _, a := range e.Args {
+			vs = append(vs, s.eval(a)...)
+		}
+		return vs
+	case *ast.CompositeLit:
+		var vs []string
+		for _, elt := range e.Elts {
+			vs = append(vs, s.eval(elt)...)
+		}
+		return vs
+	case *ast.KeyValueExpr:
+		return s.eval(e.Value)
+	case *ast.IndexExpr:
+		// map[bool]string{true: "a", false: "b"}[ok]
+		return s.evalExpr(e.X)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package pkg
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScanClasses(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.go")
+	b := filepath.Join(dir, "b.go")
+	assert.Nil(t, os.WriteFile(a, []byte(`package main
+
+import (
+	"fmt"
+	"syscall/js"
+)
+
+//wasmserve:classes bg-red-500 bg-green-500
+
+func main() {
+	p := js.Global().Get("document").Call("createElement", "p")
+	p.Set("class", "font-sans hover:font-serif text-2xl")
+	p.Set("class", "font-sans "+variant)
+	p.Set("className", "text-"+color+" w-[32px]")
+	p.Call("setAttribute", "class", fmt.Sprintf("p-%d m-4", 2))
+	p.Get("classList").Call("add", "hidden", "md:block")
+	p.Set("class", "border-"+width())
+	p.Set("id", "not-a-class")
+}
+`), 0644))
+	assert.Nil(t, os.WriteFile(b, []byte(`package main
+
+const (
+	variant = "btn " + primary
+	primary = "btn-primary"
+	color   = "red-500"
+)
+
+type Element struct{ Class string }
+
+var e = Element{Class: map[bool]string{true: "underline", false: "no-underline"}[true]}
+var attrs = map[string]string{"class": "flex"}
+
+func width() string { return "2" }
+`), 0644))
+
+	classes, err := ScanClasses([]string{a, b})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"bg-green-500", "bg-red-500", "btn", "btn-primary", "flex", "font-sans",
+		"hidden", "hover:font-serif", "m-4", "md:block", "no-underline",
+		"text-2xl", "text-red-500", "underline", "w-[32px]",
+	}, classes)
+}
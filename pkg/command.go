@@ -0,0 +1,133 @@
+package pkg
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Command is a command line in the config, either a string or an array of
+// arguments:
+//
+//	tailwind_exec = "npx tailwindcss --config 'web/tailwind.config.js'"
+//	tailwind_exec = ["C:\\Program Files\\tailwind\\tailwindcss.exe"]
+//
+// A string is split into arguments with the quoting rules of the shell: text
+// in single quotes is literal, and a backslash escapes a quote, a space or a
+// backslash outside single quotes. Other backslashes are kept, so Windows
+// paths don't need escaping. $VAR and ${VAR} are expanded in the arguments
+// when the command is run, and $$ is a literal $.
+type Command []string
+
+// ParseCommand splits s into arguments with the quoting rules of the shell.
+func ParseCommand(s string) (Command, error) {
+	var (
+		args   Command
+		arg    strings.Builder
+		inArg  bool
+		quote  rune
+		escape bool
+	)
+	for _, r := range s {
+		switch {
+		case escape:
+			escape = false
+			if r != '\'' && r != '"' && r != '\\' && !(quote == 0 && isCommandSpace(r)) {
+				arg.WriteRune('\\')
+			}
+			arg.WriteRune(r)
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				arg.WriteRune(r)
+			}
+		case r == '\\':
+			escape = true
+			inArg = true
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else {
+				arg.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case isCommandSpace(r):
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+	if escape {
+		arg.WriteRune('\\')
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c in %q", quote, s)
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
+
+func isCommandSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\n' || r == '\r'
+}
+
+// UnmarshalTOML parses a command string. Arrays are decoded as they are.
+func (c *Command) UnmarshalTOML(v interface{}) error {
+	s, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("want a string or an array of strings, got %v", v)
+	}
+	args, err := ParseCommand(s)
+	if err != nil {
+		return err
+	}
+	*c = args
+	return nil
+}
+
+// Args returns the arguments with the environment variables expanded.
+func (c Command) Args() []string {
+	args := make([]string, len(c))
+	for i, a := range c {
+		args[i] = os.Expand(a, func(name string) string {
+			if name == "$" {
+				return "$"
+			}
+			return os.Getenv(name)
+		})
+	}
+	return args
+}
+
+// String returns the command as a string that ParseCommand splits back into
+// the same arguments.
+func (c Command) String() string {
+	quoted := make([]string, len(c))
+	for i, a := range c {
+		quoted[i] = quoteCommandArg(a)
+	}
+	return strings.Join(quoted, " ")
+}
+
+func quoteCommandArg(a string) string {
+	if a == "" {
+		return "''"
+	}
+	if !strings.ContainsAny(a, " \t\r\n'\"\\") {
+		return a
+	}
+	if !strings.Contains(a, "'") {
+		return "'" + a + "'"
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(a) + `"`
+}
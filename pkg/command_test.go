@@ -0,0 +1,61 @@
+package pkg
+
+import (
+	"os"
+	"testing"
+
+	"github.com/pelletier/go-toml"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCommand(t *testing.T) {
+	cases := map[string]Command{
+		"npx tailwindcss":                      {"npx", "tailwindcss"},
+		"  npx   tailwindcss  ":                {"npx", "tailwindcss"},
+		`"/opt/my tools/tailwindcss" --minify`: {"/opt/my tools/tailwindcss", "--minify"},
+		`'/opt/my tools/tailwindcss'`:          {"/opt/my tools/tailwindcss"},
+		`/opt/my\ tools/tailwindcss`:           {"/opt/my tools/tailwindcss"},
+		`C:\tools\tailwindcss.exe`:             {`C:\tools\tailwindcss.exe`},
+		`echo "say \"hi\"" 'it''s' ''`:         {"echo", `say "hi"`, "its", ""},
+		`--content='./**/*.go' "$HOME/x"`:      {"--content=./**/*.go", "$HOME/x"},
+		"":                                     nil,
+	}
+	for in, want := range cases {
+		got, err := ParseCommand(in)
+		assert.Nil(t, err, in)
+		assert.Equal(t, want, got, in)
+		// String round-trips.
+		again, err := ParseCommand(got.String())
+		assert.Nil(t, err, in)
+		assert.Equal(t, got, again, in)
+	}
+
+	for _, in := range []string{`npx "tailwindcss`, `npx 'tailwindcss`} {
+		_, err := ParseCommand(in)
+		assert.NotNil(t, err, in)
+	}
+}
+
+func TestCommandArgs(t *testing.T) {
+	os.Setenv("WASMSERVE_TEST_DIR", "/opt/tw")
+	defer os.Unsetenv("WASMSERVE_TEST_DIR")
+	c := Command{"$WASMSERVE_TEST_DIR/tailwindcss", "${WASMSERVE_TEST_DIR}/in.css", "$$1"}
+	assert.Equal(t, []string{"/opt/tw/tailwindcss", "/opt/tw/in.css", "$1"}, c.Args())
+}
+
+func TestUnmarshalCommand(t *testing.T) {
+	var v struct {
+		A Command `toml:"a"`
+		B Command `toml:"b"`
+	}
+	err := toml.Unmarshal([]byte(`
+a = "npx 'tailwind css'"
+b = ["/opt/my tools/tailwindcss", "--minify"]
+`), &v)
+	assert.Nil(t, err)
+	assert.Equal(t, Command{"npx", "tailwind css"}, v.A)
+	assert.Equal(t, Command{"/opt/my tools/tailwindcss", "--minify"}, v.B)
+
+	err = toml.Unmarshal([]byte(`a = "npx 'tailwind"`), &v)
+	assert.NotNil(t, err)
+}
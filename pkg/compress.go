@@ -0,0 +1,132 @@
+package pkg
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha512"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// Encodings of the precompressed siblings of a file, such as main.wasm.br, by
+// their Content-Encoding, in the order they are preferred.
+var PrecompressedEncodings = []struct {
+	Encoding string
+	Ext      string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// IsPrecompressible reports whether the file name is a wasm, js or css file,
+// which get precompressed siblings in an export.
+func IsPrecompressible(name string) bool {
+	switch filepath.Ext(name) {
+	case ".wasm", ".js", ".css":
+		return true
+	}
+	return false
+}
+
+// BrotliFastQuality is the quality of BrotliFast.
+const BrotliFastQuality = 5
+
+// Brotli returns data compressed with brotli at the best compression.
+func Brotli(data []byte) ([]byte, error) {
+	return compressBrotli(data, brotli.WriterOptions{Quality: brotli.BestCompression, LGWin: 24})
+}
+
+// BrotliFast returns data compressed with brotli at BrotliFastQuality. It is
+// many times faster than Brotli, and somewhat larger, so its size is a
+// pessimistic estimate of the size of Brotli.
+func BrotliFast(data []byte) ([]byte, error) {
+	return compressBrotli(data, brotli.WriterOptions{Quality: BrotliFastQuality, LGWin: 24})
+}
+
+func compressBrotli(data []byte, o brotli.WriterOptions) ([]byte, error) {
+	var b bytes.Buffer
+	w := brotli.NewWriterOptions(&b, o)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// Gzip returns data compressed with gzip at the best compression.
+func Gzip(data []byte) ([]byte, error) {
+	var b bytes.Buffer
+	w, err := gzip.NewWriterLevel(&b, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// Precompress writes the brotli and gzip siblings of the file at path, such
+// as main.wasm.br and main.wasm.gz.
+func Precompress(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	br, err := Brotli(data)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path+".br", br, 0644); err != nil {
+		return err
+	}
+	gz, err := Gzip(data)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path+".gz", gz, 0644)
+}
+
+// Integrity returns the Subresource Integrity of data, such as
+// "sha384-oqVuAfXRKap7fdgcCY5uykM6+R9GqQ8K/uxy9rx7HNQlGYl1kPzQho1wx4JwY8wC".
+func Integrity(data []byte) string {
+	sum := sha512.Sum384(data)
+	return "sha384-" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// AcceptsEncoding reports whether the Accept-Encoding header accept allows the
+// content coding enc, such as "br". An explicit coding takes precedence over
+// "*".
+func AcceptsEncoding(accept, enc string) bool {
+	wildcard := false
+	for _, part := range strings.Split(accept, ",") {
+		fields := strings.Split(part, ";")
+		name := strings.TrimSpace(fields[0])
+		q := 1.0
+		for _, p := range fields[1:] {
+			p = strings.TrimSpace(p)
+			if strings.HasPrefix(p, "q=") {
+				if v, err := strconv.ParseFloat(strings.TrimPrefix(p, "q="), 64); err == nil {
+					q = v
+				}
+			}
+		}
+		switch {
+		case strings.EqualFold(name, enc):
+			return q > 0
+		case name == "*":
+			wildcard = q > 0
+		}
+	}
+	return wildcard
+}
@@ -0,0 +1,63 @@
+package pkg
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrecompress(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "main.wasm")
+	data := bytes.Repeat([]byte("wasm "), 1000)
+	assert.Nil(t, os.WriteFile(p, data, 0644))
+	assert.Nil(t, Precompress(p))
+
+	br, err := os.ReadFile(p + ".br")
+	assert.Nil(t, err)
+	assert.Less(t, len(br), len(data))
+	got, err := io.ReadAll(brotli.NewReader(bytes.NewReader(br)))
+	assert.Nil(t, err)
+	assert.Equal(t, data, got)
+
+	gz, err := os.ReadFile(p + ".gz")
+	assert.Nil(t, err)
+	r, err := gzip.NewReader(bytes.NewReader(gz))
+	assert.Nil(t, err)
+	got, err = io.ReadAll(r)
+	assert.Nil(t, err)
+	assert.Equal(t, data, got)
+
+	assert.True(t, IsPrecompressible("css/main.css"))
+	assert.False(t, IsPrecompressible("index.html"))
+}
+
+func TestBrotliFast(t *testing.T) {
+	data := bytes.Repeat([]byte("wasm "), 1000)
+	br, err := BrotliFast(data)
+	assert.Nil(t, err)
+	assert.Less(t, len(br), len(data))
+	got, err := io.ReadAll(brotli.NewReader(bytes.NewReader(br)))
+	assert.Nil(t, err)
+	assert.Equal(t, data, got)
+}
+
+func TestIntegrity(t *testing.T) {
+	// The example of the Subresource Integrity specification.
+	assert.Equal(t, "sha384-H8BRh8j48O9oYatfu5AZzq6A9RINhZO5H16dQZngK7T62em8MUt1FLm52t+eX6xO", Integrity([]byte("alert('Hello, world.');")))
+}
+
+func TestAcceptsEncoding(t *testing.T) {
+	assert.True(t, AcceptsEncoding("gzip, deflate, br", "br"))
+	assert.True(t, AcceptsEncoding("gzip, deflate, br", "gzip"))
+	assert.False(t, AcceptsEncoding("gzip, deflate", "br"))
+	assert.False(t, AcceptsEncoding("br;q=0, gzip", "br"))
+	assert.True(t, AcceptsEncoding("*", "br"))
+	assert.False(t, AcceptsEncoding("*, br;q=0", "br"))
+	assert.False(t, AcceptsEncoding("", "gzip"))
+}
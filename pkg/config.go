@@ -3,6 +3,9 @@ package pkg
 import (
 	"fmt"
 	"os"
+	"path"
+	"path/filepath"
+	"runtime"
 	"time"
 
 	"github.com/pelletier/go-toml"
@@ -15,33 +18,119 @@ var (
 	DefaultHttp     = "8080"
 	DefaultTags     = ""
 	// TODO Check how the toml unmarshal handles this
-	DefaultAllowOrigin = ""
-	DefaultOverlay     = ""
-	DefaultWasmFile    = "main.wasm"
-	DefaultTmpDir      = "tmp"
-	DefaultRoot        = "."
+	DefaultAllowOrigin  = ""
+	DefaultOverlay      = ""
+	DefaultWasmFile     = "main.wasm"
+	DefaultTmpDir       = "tmp"
+	DefaultRoot         = "."
+	DefaultWatchExt     = []string{"go", "tpl", "tmpl", "html", "css", "scss", "sass"}
+	DefaultWatchDelay   = 100 * time.Millisecond
+	DefaultBuildTimeout = 10 * time.Minute
+	DefaultCacheMaxSize = "1GB"
+)
+
+// CssPipelineNative is the css_pipeline that bundles and minifies the css
+// files without Tailwind in Go.
+const CssPipelineNative = "native"
+
+// Stages in which build hooks are run.
+const (
+	HookPreBuild  = "pre_build"
+	HookPostBuild = "post_build"
+)
+
+// Failure policies of build hooks.
+const (
+	// HookAbort stops the build when the hook fails. This is the default.
+	HookAbort = "abort"
+	// HookWarn logs the failure and continues the build.
+	HookWarn = "warn"
 )
 
 type config struct {
-	UseAir         bool   `toml:"use_air"`
-	TailwindExec   string `toml:"tailwind_exec,omitempty"`
-	EnableTailwind bool   `toml:"enable_tailwind"`
-	WasmFile       string `toml:"wasm_file,omitempty"`
-	Http           string `toml:"http,omitempty"`
-	Tags           string `toml:"tags,omitempty"`
-	AllowOrigin    string `toml:"allow_origin,omitempty"`
-	Overlay        string `toml:"overlay,omitempty"`
-	// Air configs
-	Root        string    `toml:"root"`
-	TmpDir      string    `toml:"tmp_dir"`
+	UseAir         bool    `toml:"use_air"`
+	TailwindExec   Command `toml:"tailwind_exec,omitempty"`
+	EnableTailwind bool    `toml:"enable_tailwind"`
+	WasmFile       string  `toml:"wasm_file,omitempty"`
+	Http           string  `toml:"http,omitempty"`
+	Tags           string  `toml:"tags,omitempty"`
+	AllowOrigin    string  `toml:"allow_origin,omitempty"`
+	Overlay        string  `toml:"overlay,omitempty"`
+	Root           string  `toml:"root"`
+	TmpDir         string  `toml:"tmp_dir"`
+	// TailwindWorkers is how many css files are built at the same time.
+	// Defaults to GOMAXPROCS.
+	TailwindWorkers int `toml:"tailwind_workers,omitempty"`
+	// CssEntries lists the globs of the css files to build. Other css files,
+	// such as partials imported by the entries, are not built on their own.
+	// Defaults to every css file.
+	CssEntries []string `toml:"css_entries,omitempty"`
+	// CssMinify minifies the css files that are built without Tailwind.
+	CssMinify bool `toml:"css_minify,omitempty"`
+	// CssPipeline is CssPipelineNative to bundle the @imports of the css files
+	// without Tailwind and minify them, even if Tailwind is disabled. By
+	// default, they are copied.
+	CssPipeline string `toml:"css_pipeline,omitempty"`
+	// HashFilenames copies the wasm, css and js outputs to content-hashed
+	// names, such as main.3f2a1b9c0d.wasm, listed in the ManifestFile of
+	// tmp_dir. The index page refers to them with the asset template function.
+	HashFilenames bool `toml:"hash_filenames,omitempty"`
+	// CssProcessors selects the processors of the stylesheets by glob. The
+	// first matching rule is used. Sass files default to the sass processor.
+	CssProcessors []CssProcessorRule `toml:"css_processors,omitempty"`
+	// CssSourceMaps keeps the source maps of the sass and postcss processors
+	// next to the outputs. Defaults to true.
+	CssSourceMaps *bool `toml:"css_source_maps,omitempty"`
+	// SassExec is the command of the sass processor. Defaults to
+	// DefaultSassExec, the dart-sass executable.
+	SassExec Command `toml:"sass_exec,omitempty"`
+	// PostcssExec is the command of the postcss processor. Defaults to
+	// DefaultPostcssExec.
+	PostcssExec Command `toml:"postcss_exec,omitempty"`
+	// WasmOptExec is the command of wasm-opt, which the profiles with wasm_opt
+	// run on the wasm. Defaults to DefaultWasmOptExec.
+	WasmOptExec Command `toml:"wasm_opt_exec,omitempty"`
+	// TailwindWatch makes the dev server keep a `tailwindcss --watch` process
+	// running for every css file, instead of running Tailwind for every build.
+	TailwindWatch bool `toml:"tailwind_watch,omitempty"`
+	// TailwindVersion pins the standalone Tailwind executable to download, such
+	// as "v4.1.3" or "latest". It is used instead of TailwindExec.
+	TailwindVersion string `toml:"tailwind_version,omitempty"`
+	// TailwindDir is the working directory of Tailwind, such as the directory
+	// of package.json for npx. Defaults to the current directory.
+	TailwindDir string `toml:"tailwind_dir,omitempty"`
+	// TailwindDownloadURL is the releases page the executables are downloaded
+	// from. Defaults to DefaultTailwindDownloadURL.
+	TailwindDownloadURL string `toml:"tailwind_download_url,omitempty"`
+	// BuildTimeout is the longest a build may take, as a duration such as "90s".
+	BuildTimeout string `toml:"build_timeout,omitempty"`
+	// Commands run before and after each build
+	PreBuild  []Hook      `toml:"pre_build,omitempty"`
+	PostBuild []Hook      `toml:"post_build,omitempty"`
+	Watch     cfgWatch    `toml:"watch"`
+	Cache     cfgCache    `toml:"cache,omitempty"`
+	Tailwind  cfgTailwind `toml:"tailwind,omitempty"`
+	Js        cfgJs       `toml:"js,omitempty"`
+	Export    cfgExport   `toml:"export,omitempty"`
+	// SizeBudgets are the largest sizes of the outputs. wasmserve build fails
+	// when an output is over its budget, and wasmserve dev warns.
+	SizeBudgets []SizeBudget `toml:"size_budget,omitempty"`
+	// Profiles are the [profile.<name>] tables, which change the settings of
+	// the built-in profiles or define others.
+	Profiles map[string]cfgProfile `toml:"profile,omitempty"`
+	// Air configs. Only the watch related settings of [build] are still used,
+	// as fallbacks for [watch].
 	TestDataDir string    `toml:"testdata_dir,omitempty"`
-	Build       cfgBuild  `toml:"build"`
-	Color       cfgColor  `toml:"color"`
-	Log         cfgLog    `toml:"log"`
-	Misc        cfgMisc   `toml:"misc"`
-	Screen      cfgScreen `toml:"screen"`
+	Build       cfgBuild  `toml:"build,omitempty"`
+	Color       cfgColor  `toml:"color,omitempty"`
+	Log         cfgLog    `toml:"log,omitempty"`
+	Misc        cfgMisc   `toml:"misc,omitempty"`
+	Screen      cfgScreen `toml:"screen,omitempty"`
 
 	WasmPath string `commented:"true"`
+
+	// profile is the profile selected by UseProfile.
+	profile *BuildProfile
 }
 
 type cfgBuild struct {
@@ -63,6 +152,62 @@ type cfgBuild struct {
 	KillDelay        time.Duration `toml:"kill_delay"`
 }
 
+type cfgWatch struct {
+	// Include lists the globs of the files that trigger a build, relative to Root.
+	Include []string `toml:"include"`
+	// Exclude lists the globs of the files and directories that are never watched.
+	Exclude []string `toml:"exclude"`
+	// Gitignore makes the watcher skip the files ignored by .gitignore files.
+	// Defaults to true.
+	Gitignore *bool `toml:"gitignore"`
+	// Delay is how long the watcher waits for more changes before building, in milliseconds.
+	Delay int `toml:"delay,omitempty"`
+}
+
+type cfgCache struct {
+	// Disable turns the wasm build cache off.
+	Disable bool `toml:"disable,omitempty"`
+	// Dir defaults to the wasm directory in DefaultCacheDir.
+	Dir string `toml:"dir,omitempty"`
+	// MaxSize is the size over which old artifacts are evicted, such as "1GB".
+	MaxSize string `toml:"max_size,omitempty"`
+}
+
+// TailwindOptions are the options of Tailwind for a css file.
+type TailwindOptions struct {
+	// Config is the configuration file. Defaults to the tailwind.config.js in
+	// tailwind_dir for Tailwind v3, and to none for v4.
+	Config string `toml:"config,omitempty"`
+	// Minify minifies the output.
+	Minify *bool `toml:"minify,omitempty"`
+	// Content lists the globs of the files scanned for classes. Defaults to the
+	// Go files of the local packages in the build graph.
+	Content []string `toml:"content,omitempty"`
+	// Postcss is the PostCSS configuration file. Tailwind v3 only.
+	Postcss string `toml:"postcss,omitempty"`
+	// ExtraArgs are added to the command line of Tailwind.
+	ExtraArgs Command `toml:"extra_args,omitempty"`
+}
+
+type cfgTailwind struct {
+	TailwindOptions
+	// ScanClasses makes wasmserve find the classes of the Go files with
+	// ScanClasses, and give them to Tailwind. Defaults to true.
+	ScanClasses *bool `toml:"scan_classes,omitempty"`
+	// Entries overrides the options of the css files by their input paths.
+	Entries map[string]TailwindOptions `toml:"entries,omitempty"`
+}
+
+// Hook is a command run before or after a build.
+type Hook struct {
+	// Cmd is run with the system shell.
+	Cmd string `toml:"cmd"`
+	// Dir is the working directory of the command. Defaults to the current directory.
+	Dir string `toml:"dir,omitempty"`
+	// OnFailure is either HookAbort or HookWarn. Defaults to HookAbort.
+	OnFailure string `toml:"on_failure,omitempty"`
+}
+
 type cfgColor struct {
 	Main    string `toml:"main"`
 	Watcher string `toml:"watcher"`
@@ -83,6 +228,169 @@ type cfgScreen struct {
 	ClearOnRebuild bool `toml:"clear_on_rebuild"`
 }
 
+// TailwindWorkerCount returns how many css files are built at the same time.
+func (c *config) TailwindWorkerCount() int {
+	if c.TailwindWorkers > 0 {
+		return c.TailwindWorkers
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+// BuildTimeoutDuration returns the longest a build may take.
+func (c *config) BuildTimeoutDuration() time.Duration {
+	if c.BuildTimeout == "" {
+		return DefaultBuildTimeout
+	}
+	// The value is validated by ReadConfig.
+	d, _ := time.ParseDuration(c.BuildTimeout)
+	return d
+}
+
+// WasmCache returns the cache of the built wasm files.
+func (c *config) WasmCache() (*Cache, error) {
+	dir := c.Cache.Dir
+	if dir == "" {
+		d, err := DefaultCacheDir()
+		if err != nil {
+			return nil, err
+		}
+		dir = filepath.Join(d, "wasm")
+	}
+	max := c.Cache.MaxSize
+	if max == "" {
+		max = DefaultCacheMaxSize
+	}
+	size, err := ParseSize(max)
+	if err != nil {
+		return nil, fmt.Errorf("cache.max_size: %v", err)
+	}
+	return &Cache{Dir: dir, MaxSize: size}, nil
+}
+
+// TailwindOptionsFor returns the [tailwind] options of the css file input,
+// with the options set in its [tailwind.entries] table replacing the others.
+// Minify defaults to the minify of the build profile.
+func (c *config) TailwindOptionsFor(input string) TailwindOptions {
+	o := c.Tailwind.TailwindOptions
+	for k, e := range c.Tailwind.Entries {
+		if path.Clean(filepath.ToSlash(k)) != path.Clean(filepath.ToSlash(input)) {
+			continue
+		}
+		if e.Config != "" {
+			o.Config = e.Config
+		}
+		if e.Minify != nil {
+			o.Minify = e.Minify
+		}
+		if e.Content != nil {
+			o.Content = e.Content
+		}
+		if e.Postcss != "" {
+			o.Postcss = e.Postcss
+		}
+		if e.ExtraArgs != nil {
+			o.ExtraArgs = e.ExtraArgs
+		}
+	}
+	if o.Minify == nil && c.BuildProfile().Minify {
+		minify := true
+		o.Minify = &minify
+	}
+	return o
+}
+
+// CssEnabled reports whether the stylesheets are built, by Tailwind, by the
+// native pipeline or by the processors of css_processors or sass_exec.
+func (c *config) CssEnabled() bool {
+	return c.EnableTailwind || c.CssPipeline == CssPipelineNative || len(c.CssProcessors) > 0 || len(c.SassExec) > 0
+}
+
+// TailwindScanClasses reports whether the classes of the Go files are given
+// to Tailwind.
+func (c *config) TailwindScanClasses() bool {
+	return c.Tailwind.ScanClasses == nil || *c.Tailwind.ScanClasses
+}
+
+// TailwindRelease returns the downloader of the standalone Tailwind
+// executables, which are stored in the tailwind directory of DefaultCacheDir.
+func (c *config) TailwindRelease() (*TailwindRelease, error) {
+	d, err := DefaultCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	url := c.TailwindDownloadURL
+	if url == "" {
+		url = DefaultTailwindDownloadURL
+	}
+	return &TailwindRelease{BaseURL: url, Dir: filepath.Join(d, "tailwind")}, nil
+}
+
+// WatchInclude returns the globs of the watched files. Without [watch]
+// include, they are made from the air settings include_ext and include_dir.
+// The JavaScript and TypeScript files are watched when [js] has entries.
+func (c *config) WatchInclude() []string {
+	globs := append([]string{}, c.watchInclude()...)
+	if c.JsEnabled() {
+		for _, ext := range JsExts {
+			globs = append(globs, "**/*"+ext)
+		}
+	}
+	return globs
+}
+
+func (c *config) watchInclude() []string {
+	if len(c.Watch.Include) > 0 {
+		return c.Watch.Include
+	}
+	exts := c.Build.IncludeExt
+	if len(exts) == 0 {
+		exts = DefaultWatchExt
+	}
+	dirs := c.Build.IncludeDir
+	if len(dirs) == 0 {
+		dirs = []string{""}
+	}
+	var globs []string
+	for _, d := range dirs {
+		for _, e := range exts {
+			globs = append(globs, path.Join(filepath.ToSlash(d), "**", "*."+e))
+		}
+	}
+	return globs
+}
+
+// WatchExclude returns the globs of the files and directories that are not
+// watched: tmp_dir, the export directory, [watch] exclude and the globs made
+// from the air settings exclude_dir and exclude_file.
+func (c *config) WatchExclude() []string {
+	globs := append([]string{}, c.Watch.Exclude...)
+	globs = append(globs, path.Join(filepath.ToSlash(path.Clean(c.TmpDir)), "**"))
+	globs = append(globs, path.Join(filepath.ToSlash(path.Clean(c.ExportDir())), "**"))
+	for _, d := range c.Build.ExcludeDir {
+		globs = append(globs, path.Join(filepath.ToSlash(d), "**"))
+	}
+	for _, f := range c.Build.ExcludeFile {
+		globs = append(globs, filepath.ToSlash(f))
+	}
+	return globs
+}
+
+// WatchGitignore reports whether the files ignored by .gitignore are skipped.
+func (c *config) WatchGitignore() bool {
+	return c.Watch.Gitignore == nil || *c.Watch.Gitignore
+}
+
+// WatchDelay returns how long to wait for more changes before building.
+func (c *config) WatchDelay() time.Duration {
+	if c.Watch.Delay > 0 {
+		return time.Duration(c.Watch.Delay) * time.Millisecond
+	}
+	if c.Build.Delay > 0 {
+		return time.Duration(c.Build.Delay) * time.Millisecond
+	}
+	return DefaultWatchDelay
+}
+
 func ReadConfig(path string) (*config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -93,10 +401,64 @@ func ReadConfig(path string) (*config, error) {
 		return nil, err
 	}
 	conf.WasmPath = fmt.Sprintf("%s/%s", conf.TmpDir, conf.WasmFile)
+	if conf.BuildTimeout != "" {
+		if d, err := time.ParseDuration(conf.BuildTimeout); err != nil {
+			return nil, fmt.Errorf("build_timeout: %v", err)
+		} else if d <= 0 {
+			return nil, fmt.Errorf("build_timeout: must be positive")
+		}
+	}
+	if conf.Cache.MaxSize != "" {
+		if _, err := ParseSize(conf.Cache.MaxSize); err != nil {
+			return nil, fmt.Errorf("cache.max_size: %v", err)
+		}
+	}
+	switch conf.CssPipeline {
+	case "", CssPipelineNative:
+	default:
+		return nil, fmt.Errorf("css_pipeline: unknown pipeline %q (want %q)", conf.CssPipeline, CssPipelineNative)
+	}
+	if err := validateCssProcessors(conf.CssProcessors); err != nil {
+		return nil, err
+	}
+	if err := validateJs(conf.Js); err != nil {
+		return nil, err
+	}
+	if err := validateSizeBudgets(conf.SizeBudgets); err != nil {
+		return nil, err
+	}
+	if conf.TailwindVersion != "" {
+		if len(conf.TailwindExec) > 0 {
+			return nil, fmt.Errorf("tailwind_exec and tailwind_version can't be used together")
+		}
+		if _, err := NormalizeTailwindVersion(conf.TailwindVersion); err != nil {
+			return nil, fmt.Errorf("tailwind_version: %v", err)
+		}
+	}
+	if err := validateHooks(HookPreBuild, conf.PreBuild); err != nil {
+		return nil, err
+	}
+	if err := validateHooks(HookPostBuild, conf.PostBuild); err != nil {
+		return nil, err
+	}
 
 	return conf, nil
 }
 
+func validateHooks(stage string, hooks []Hook) error {
+	for i, h := range hooks {
+		if h.Cmd == "" {
+			return fmt.Errorf("%s[%d]: cmd is empty", stage, i)
+		}
+		switch h.OnFailure {
+		case "", HookAbort, HookWarn:
+		default:
+			return fmt.Errorf("%s[%d]: unknown on_failure %q (want %q or %q)", stage, i, h.OnFailure, HookAbort, HookWarn)
+		}
+	}
+	return nil
+}
+
 func DefaultConfig() config {
 	return config{
 		UseAir:         false,
@@ -114,7 +476,7 @@ func DefaultConfig() config {
 
 func DefaultTomlContent() config {
 	return config{
-		UseAir:         true,
+		UseAir:         false,
 		EnableTailwind: false,
 		WasmFile:       DefaultWasmFile,
 		Http:           DefaultHttp,
@@ -123,35 +485,9 @@ func DefaultTomlContent() config {
 		Overlay:        DefaultOverlay,
 		Root:           DefaultRoot,
 		TmpDir:         DefaultTmpDir,
-		Build: cfgBuild{
-			Cmd:              "wasmserve build",
-			Bin:              "wasmserve run",
-			FullBin:          "wasmserve run",
-			IncludeExt:       []string{"go", "tpl", "tmpl", "html", "css"},
-			ExcludeDir:       []string{"assets", "tmp", "vendor", "frontend/node_modules"},
-			IncludeDir:       []string{},
-			ExcludeFile:      []string{},
-			ExcludeRegex:     []string{"_test.go"},
-			ExcludeUnchanged: true,
-			FollowSymlink:    true,
-			Log:              "air.log",
-			Delay:            1000,
-			StopOnError:      true,
-			SendInterrupt:    true,
-			KillDelay:        400,
-			ArgsBin:          []string{},
-		},
-		Log: cfgLog{
-			AddTime: false,
-		},
-		Color: cfgColor{
-			Main:    "magenta",
-			Watcher: "cyan",
-			Build:   "yellow",
-			Runner:  "green",
-		},
-		Misc: cfgMisc{
-			CleanOnExit: false,
+		Watch: cfgWatch{
+			Include: []string{"**/*.go", "**/*.tpl", "**/*.tmpl", "**/*.html", "**/*.css", "**/*.scss", "**/*.sass"},
+			Exclude: []string{"assets/**", "vendor/**", "**/node_modules/**", "**/*_test.go"},
 		},
 	}
 }
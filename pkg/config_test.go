@@ -1,7 +1,10 @@
 package pkg
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/pelletier/go-toml"
 	"github.com/stretchr/testify/assert"
@@ -11,3 +14,122 @@ func TestDefaultTomlContent(t *testing.T) {
 	_, err := toml.Marshal(DefaultTomlContent())
 	assert.Nil(t, err)
 }
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), DefaultTomlFile)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadConfigHooks(t *testing.T) {
+	conf, err := ReadConfig(writeConfig(t, `
+tmp_dir = "tmp"
+wasm_file = "main.wasm"
+
+[[pre_build]]
+cmd = "go generate ./..."
+
+[[post_build]]
+cmd = "cp tmp/main.wasm site/static"
+on_failure = "warn"
+`))
+	assert.Nil(t, err)
+	assert.Equal(t, []Hook{{Cmd: "go generate ./..."}}, conf.PreBuild)
+	assert.Equal(t, []Hook{{Cmd: "cp tmp/main.wasm site/static", OnFailure: HookWarn}}, conf.PostBuild)
+}
+
+func TestReadConfigInvalidHook(t *testing.T) {
+	_, err := ReadConfig(writeConfig(t, `
+[[pre_build]]
+cmd = "go generate ./..."
+on_failure = "ignore"
+`))
+	assert.NotNil(t, err)
+
+	_, err = ReadConfig(writeConfig(t, `
+[[post_build]]
+on_failure = "warn"
+`))
+	assert.NotNil(t, err)
+}
+
+func TestWatchFallbacks(t *testing.T) {
+	conf, err := ReadConfig(writeConfig(t, `
+tmp_dir = "tmp"
+
+[build]
+include_ext = ["go"]
+exclude_dir = ["vendor"]
+delay = 500
+`))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"**/*.go"}, conf.WatchInclude())
+	assert.Equal(t, []string{"tmp/**", "dist/**", "vendor/**"}, conf.WatchExclude())
+	assert.Equal(t, 500*time.Millisecond, conf.WatchDelay())
+	assert.True(t, conf.WatchGitignore())
+
+	conf, err = ReadConfig(writeConfig(t, `
+tmp_dir = "tmp"
+
+[watch]
+include = ["src/**/*.go"]
+gitignore = false
+`))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"src/**/*.go"}, conf.WatchInclude())
+	assert.Equal(t, DefaultWatchDelay, conf.WatchDelay())
+	assert.False(t, conf.WatchGitignore())
+}
+
+func TestBuildTimeout(t *testing.T) {
+	conf, err := ReadConfig(writeConfig(t, `build_timeout = "90s"`))
+	assert.Nil(t, err)
+	assert.Equal(t, 90*time.Second, conf.BuildTimeoutDuration())
+
+	conf, err = ReadConfig(writeConfig(t, ``))
+	assert.Nil(t, err)
+	assert.Equal(t, DefaultBuildTimeout, conf.BuildTimeoutDuration())
+
+	_, err = ReadConfig(writeConfig(t, `build_timeout = "soon"`))
+	assert.NotNil(t, err)
+}
+
+func TestTailwindVersion(t *testing.T) {
+	conf, err := ReadConfig(writeConfig(t, `tailwind_version = "4.1.3"`))
+	assert.Nil(t, err)
+	assert.Equal(t, "4.1.3", conf.TailwindVersion)
+
+	_, err = ReadConfig(writeConfig(t, `tailwind_version = "four"`))
+	assert.NotNil(t, err)
+	_, err = ReadConfig(writeConfig(t, "tailwind_version = \"latest\"\ntailwind_exec = \"npx tailwindcss\""))
+	assert.NotNil(t, err)
+}
+
+func TestTailwindOptions(t *testing.T) {
+	conf, err := ReadConfig(writeConfig(t, `
+[tailwind]
+config = "tailwind.config.js"
+minify = true
+content = ["**/*.go", "web/**/*.html"]
+extra_args = "--watch=always"
+
+[tailwind.entries."css/admin.css"]
+config = "admin.config.js"
+minify = false
+`))
+	assert.Nil(t, err)
+
+	o := conf.TailwindOptionsFor("styles.css")
+	assert.Equal(t, "tailwind.config.js", o.Config)
+	assert.True(t, *o.Minify)
+	assert.Equal(t, []string{"**/*.go", "web/**/*.html"}, o.Content)
+	assert.Equal(t, Command{"--watch=always"}, o.ExtraArgs)
+
+	o = conf.TailwindOptionsFor("./css/admin.css")
+	assert.Equal(t, "admin.config.js", o.Config)
+	assert.False(t, *o.Minify)
+	assert.Equal(t, []string{"**/*.go", "web/**/*.html"}, o.Content)
+}
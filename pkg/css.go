@@ -0,0 +1,96 @@
+package pkg
+
+import (
+	"bytes"
+	"regexp"
+)
+
+// tailwindDirective matches the at-rules of Tailwind v3 and v4.
+var tailwindDirective = regexp.MustCompile(`@(tailwind|apply|config|plugin|source|theme|utility|variant|custom-variant|reference)\b|@import\s+(url\(\s*)?["']tailwindcss(/[^"']*)?["']`)
+
+// UsesTailwind reports whether the stylesheet has Tailwind directives, and so
+// needs to be built by Tailwind.
+func UsesTailwind(src []byte) bool {
+	return tailwindDirective.Match(stripCssComments(src))
+}
+
+func stripCssComments(src []byte) []byte {
+	var dst []byte
+	for {
+		i := bytes.Index(src, []byte("/*"))
+		if i < 0 {
+			return append(dst, src...)
+		}
+		dst = append(dst, src[:i]...)
+		j := bytes.Index(src[i+2:], []byte("*/"))
+		if j < 0 {
+			return dst
+		}
+		src = src[i+2+j+2:]
+	}
+}
+
+func isCssSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+// isCssPunct reports whether spaces around c can be removed.
+func isCssPunct(c byte) bool {
+	switch c {
+	case '{', '}', ';', ',', '>':
+		return true
+	}
+	return false
+}
+
+// MinifyCss removes the comments and the unneeded whitespace of a stylesheet.
+// Strings are kept as they are.
+func MinifyCss(src []byte) []byte {
+	dst := make([]byte, 0, len(src))
+	space := false
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case c == '/' && i+1 < len(src) && src[i+1] == '*':
+			end := bytes.Index(src[i+2:], []byte("*/"))
+			if end < 0 {
+				i = len(src)
+			} else {
+				i += 2 + end + 1
+			}
+			space = true
+			continue
+		case isCssSpace(c):
+			space = true
+			continue
+		case c == '"' || c == '\'':
+			if space && len(dst) > 0 && !isCssPunct(dst[len(dst)-1]) {
+				dst = append(dst, ' ')
+			}
+			space = false
+			j := i + 1
+			for ; j < len(src) && src[j] != c; j++ {
+				if src[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(src) {
+				j = len(src) - 1
+			}
+			dst = append(dst, src[i:j+1]...)
+			i = j
+			continue
+		}
+
+		if space && len(dst) > 0 && !isCssPunct(dst[len(dst)-1]) && !isCssPunct(c) {
+			dst = append(dst, ' ')
+		}
+		space = false
+		// The last semicolon of a block is not needed.
+		if c == '}' && len(dst) > 0 && dst[len(dst)-1] == ';' {
+			dst = dst[:len(dst)-1]
+		}
+		dst = append(dst, c)
+	}
+	return dst
+}
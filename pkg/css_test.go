@@ -0,0 +1,38 @@
+package pkg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUsesTailwind(t *testing.T) {
+	cases := map[string]bool{
+		"@tailwind base;\n@tailwind utilities;":    true,
+		`@import "tailwindcss";`:                   true,
+		`@import url('tailwindcss');`:              true,
+		".btn { @apply px-4 py-2; }":               true,
+		".some { background-color: red; }":         false,
+		`@import "./partial.css";`:                 false,
+		"/* @tailwind base; */ .a { color: red; }": false,
+		"@theme { --color-brand: #123456; }":       true,
+		`@source "../**/*.go";`:                    true,
+		`@import "tailwindcss/utilities.css";`:     true,
+		"@media print { .a { color: red; } }":      false,
+	}
+	for src, want := range cases {
+		assert.Equal(t, want, UsesTailwind([]byte(src)), src)
+	}
+}
+
+func TestMinifyCss(t *testing.T) {
+	cases := map[string]string{
+		".some {\n    background-color: red;\n    border: 1px black;\n}\n": ".some{background-color: red;border: 1px black}",
+		"/* comment */\na > b ,\nc  d { x: y }":                            "a>b,c d{x: y}",
+		`.a::before { content: "  { ; }  "; }`:                             `.a::before{content: "  { ; }  "}`,
+		"@media (min-width: 640px) {\n  .a { color: red; }\n}":             "@media (min-width: 640px){.a{color: red}}",
+	}
+	for src, want := range cases {
+		assert.Equal(t, want, string(MinifyCss([]byte(src))), src)
+	}
+}
@@ -0,0 +1,318 @@
+package pkg
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// BundleCss returns the stylesheet at entry with its @import rules replaced by
+// the imported files. Imports with media queries, layer() or supports() are
+// wrapped in the matching at-rules, and remote imports are kept at the top,
+// after the @charset of entry, which must be the first rule of the file. Each
+// file is included once, so import cycles are broken.
+//
+// The bundle is served from the URL of entry, so the relative url()s of the
+// imported files are rewritten to be relative to the directory of entry.
+func BundleCss(entry string) ([]byte, error) {
+	abs, err := filepath.Abs(entry)
+	if err != nil {
+		return nil, err
+	}
+	b := &cssBundler{
+		entry: abs,
+		seen:  map[string]bool{},
+	}
+	body, err := b.bundle(abs)
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	if b.charset != "" {
+		out.WriteString(b.charset)
+		out.WriteString("\n")
+	}
+	for _, r := range b.remote {
+		out.WriteString(r)
+		out.WriteString("\n")
+	}
+	out.Write(body)
+	return out.Bytes(), nil
+}
+
+type cssBundler struct {
+	// entry and the keys of seen are absolute paths.
+	entry   string
+	seen    map[string]bool
+	charset string
+	remote  []string
+}
+
+func (b *cssBundler) bundle(file string) ([]byte, error) {
+	if b.seen[file] {
+		return nil, nil
+	}
+	b.seen[file] = true
+
+	src, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if dir, root := filepath.Dir(file), filepath.Dir(b.entry); dir != root {
+		src = rewriteCssURLs(src, dir, root)
+	}
+
+	var out bytes.Buffer
+	depth := 0
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '/' && i+1 < len(src) && src[i+1] == '*':
+			end := cssCommentEnd(src, i)
+			out.Write(src[i:end])
+			i = end
+		case c == '"' || c == '\'':
+			end := cssStringEnd(src, i)
+			out.Write(src[i:end])
+			i = end
+		case c == '{':
+			depth++
+			out.WriteByte(c)
+			i++
+		case c == '}':
+			depth--
+			out.WriteByte(c)
+			i++
+		case c == '@' && depth == 0 && hasCssKeyword(src[i:], "@charset"):
+			// Only the charset of the entry is kept, and it is moved before
+			// the remote imports.
+			end := cssRuleEnd(src, i)
+			if file == b.entry && b.charset == "" {
+				b.charset = strings.TrimSpace(string(src[i:end]))
+				if end < len(src) && src[end] == '\n' {
+					end++
+				}
+			}
+			i = end
+		case c == '@' && depth == 0 && hasCssKeyword(src[i:], "@import"):
+			end := cssRuleEnd(src, i)
+			imported, err := b.importRule(file, strings.TrimSpace(string(src[i:end])))
+			if err != nil {
+				return nil, err
+			}
+			out.Write(imported)
+			i = end
+		default:
+			out.WriteByte(c)
+			i++
+		}
+	}
+	return out.Bytes(), nil
+}
+
+// importRule returns the contents of the file imported by rule, such as
+// `@import url("a.css") screen;`, wrapped in the at-rules of its conditions.
+func (b *cssBundler) importRule(file, rule string) ([]byte, error) {
+	s := strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(rule, "@import"), ";"))
+	url, rest, ok := parseCssImportURL(s)
+	if !ok {
+		return nil, fmt.Errorf("%s: invalid %s", file, rule)
+	}
+	if isRemoteCssURL(url) {
+		b.remote = append(b.remote, rule)
+		return nil, nil
+	}
+
+	var layer, supports string
+	rest = strings.TrimSpace(rest)
+	switch {
+	case strings.HasPrefix(rest, "layer("):
+		end := matchingParen(rest, len("layer"))
+		if end < 0 {
+			return nil, fmt.Errorf("%s: invalid %s", file, rule)
+		}
+		layer = " " + strings.TrimSpace(rest[len("layer("):end-1])
+		rest = strings.TrimSpace(rest[end:])
+	case rest == "layer" || strings.HasPrefix(rest, "layer "):
+		layer = " "
+		rest = strings.TrimSpace(strings.TrimPrefix(rest, "layer"))
+	}
+	if strings.HasPrefix(rest, "supports(") {
+		end := matchingParen(rest, len("supports"))
+		if end < 0 {
+			return nil, fmt.Errorf("%s: invalid %s", file, rule)
+		}
+		supports = rest[len("supports"):end]
+		rest = strings.TrimSpace(rest[end:])
+	}
+	media := rest
+
+	p := strings.SplitN(strings.SplitN(url, "?", 2)[0], "#", 2)[0]
+	body, err := b.bundle(filepath.Join(filepath.Dir(file), filepath.FromSlash(p)))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	if media != "" {
+		body = []byte("@media " + media + " {\n" + string(body) + "\n}")
+	}
+	if supports != "" {
+		body = []byte("@supports " + supports + " {\n" + string(body) + "\n}")
+	}
+	if layer != "" {
+		body = []byte("@layer" + layer + " {\n" + string(body) + "\n}")
+	}
+	return body, nil
+}
+
+// parseCssImportURL splits the prelude of an @import rule into the URL and
+// the conditions.
+func parseCssImportURL(s string) (url, rest string, ok bool) {
+	switch {
+	case strings.HasPrefix(s, `"`) || strings.HasPrefix(s, `'`):
+		end := cssStringEnd([]byte(s), 0)
+		if end < 2 || s[end-1] != s[0] {
+			return "", "", false
+		}
+		return s[1 : end-1], s[end:], true
+	case strings.HasPrefix(s, "url("):
+		end := matchingParen(s, len("url"))
+		if end < 0 {
+			return "", "", false
+		}
+		return strings.Trim(strings.TrimSpace(s[len("url("):end-1]), `"'`), s[end:], true
+	}
+	return "", "", false
+}
+
+// isRemoteCssURL reports whether url is absolute, such as
+// "https://fonts.googleapis.com/css" or "//cdn.example.com/a.css".
+func isRemoteCssURL(url string) bool {
+	if strings.HasPrefix(url, "//") {
+		return true
+	}
+	i := strings.IndexAny(url, ":/?#")
+	return i > 0 && url[i] == ':'
+}
+
+// rewriteCssURLs rewrites the relative url()s of a stylesheet in the directory
+// dir to be relative to the directory root.
+func rewriteCssURLs(src []byte, dir, root string) []byte {
+	rel, err := filepath.Rel(root, dir)
+	if err != nil {
+		return src
+	}
+	rel = filepath.ToSlash(rel)
+
+	var out bytes.Buffer
+	for i := 0; i < len(src); {
+		switch c := src[i]; {
+		case c == '/' && i+1 < len(src) && src[i+1] == '*':
+			end := cssCommentEnd(src, i)
+			out.Write(src[i:end])
+			i = end
+		case c == '"' || c == '\'':
+			end := cssStringEnd(src, i)
+			out.Write(src[i:end])
+			i = end
+		case c == '@' && hasCssKeyword(src[i:], "@import"):
+			// The imports are resolved from the directory of their file.
+			end := cssRuleEnd(src, i)
+			out.Write(src[i:end])
+			i = end
+		case hasCssKeyword(src[i:], "url(") && (i == 0 || !isCssIdent(src[i-1])):
+			end := matchingParen(string(src[i:]), len("url"))
+			if end < 0 {
+				out.Write(src[i:])
+				return out.Bytes()
+			}
+			arg := strings.TrimSpace(string(src[i+len("url(") : i+end-1]))
+			quote := ""
+			if len(arg) >= 2 && (arg[0] == '"' || arg[0] == '\'') && arg[len(arg)-1] == arg[0] {
+				quote = arg[:1]
+				arg = arg[1 : len(arg)-1]
+			}
+			if arg != "" && !isRemoteCssURL(arg) && !strings.HasPrefix(arg, "/") && !strings.HasPrefix(arg, "#") {
+				arg = path.Join(rel, arg)
+			}
+			out.WriteString("url(" + quote + arg + quote + ")")
+			i += end
+		default:
+			out.WriteByte(c)
+			i++
+		}
+	}
+	return out.Bytes()
+}
+
+func isCssIdent(c byte) bool {
+	return c == '-' || c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// hasCssKeyword reports whether src starts with the keyword kw, ignoring case.
+func hasCssKeyword(src []byte, kw string) bool {
+	if len(src) < len(kw) || !strings.EqualFold(string(src[:len(kw)]), kw) {
+		return false
+	}
+	// "@import" is not "@imports".
+	return strings.HasSuffix(kw, "(") || len(src) == len(kw) || !isCssIdent(src[len(kw)])
+}
+
+// cssCommentEnd returns the index after the comment that starts at i.
+func cssCommentEnd(src []byte, i int) int {
+	end := bytes.Index(src[i+2:], []byte("*/"))
+	if end < 0 {
+		return len(src)
+	}
+	return i + 2 + end + 2
+}
+
+// cssStringEnd returns the index after the string that starts at i.
+func cssStringEnd(src []byte, i int) int {
+	q := src[i]
+	for j := i + 1; j < len(src); j++ {
+		switch src[j] {
+		case '\\':
+			j++
+		case q, '\n':
+			return j + 1
+		}
+	}
+	return len(src)
+}
+
+// cssRuleEnd returns the index after the semicolon that ends the at-rule that
+// starts at i.
+func cssRuleEnd(src []byte, i int) int {
+	for j := i; j < len(src); j++ {
+		switch src[j] {
+		case '"', '\'':
+			j = cssStringEnd(src, j) - 1
+		case ';':
+			return j + 1
+		}
+	}
+	return len(src)
+}
+
+// matchingParen returns the index after the parenthesis that closes the one
+// at open in s, or -1.
+func matchingParen(s string, open int) int {
+	depth := 0
+	for j := open; j < len(s); j++ {
+		switch s[j] {
+		case '"', '\'':
+			j = cssStringEnd([]byte(s), j) - 1
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return j + 1
+			}
+		}
+	}
+	return -1
+}
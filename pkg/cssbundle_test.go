@@ -0,0 +1,65 @@
+package pkg
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBundleCss(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"css/main.css": `@charset "utf-8";
+@import "base.css";
+@import url(parts/buttons.css) screen and (min-width: 640px);
+@import 'parts/layout.css' layer(layout);
+@import url("https://fonts.googleapis.com/css?family=Inter");
+.main { background: url(img/bg.png); }
+`,
+		"css/base.css": `body { margin: 0; }
+@import "main.css";
+`,
+		"css/parts/buttons.css": `@charset "utf-8";
+.btn { background: url("icons/btn.svg"), url(/abs.png), url(data:image/png;base64,AA==); }
+/* url(comment.png) */
+`,
+		"css/parts/layout.css": `@import url(grid.css) supports(display: grid);
+.layout { background-image: url('../img/layout.png#x'); }
+`,
+		"css/parts/grid.css": `.grid { display: grid; }`,
+	}
+	for name, src := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		assert.Nil(t, os.MkdirAll(filepath.Dir(p), 0755))
+		assert.Nil(t, os.WriteFile(p, []byte(src), 0644))
+	}
+
+	out, err := BundleCss(filepath.Join(dir, "css", "main.css"))
+	assert.Nil(t, err)
+	assert.Equal(t, `@charset "utf-8";
+@import url("https://fonts.googleapis.com/css?family=Inter");
+body { margin: 0; }
+
+
+@media screen and (min-width: 640px) {
+
+.btn { background: url("parts/icons/btn.svg"), url(/abs.png), url(data:image/png;base64,AA==); }
+/* url(comment.png) */
+
+}
+@layer layout {
+@supports (display: grid) {
+.grid { display: grid; }
+}
+.layout { background-image: url('img/layout.png#x'); }
+
+}
+
+.main { background: url(img/bg.png); }
+`, string(out))
+
+	_, err = BundleCss(filepath.Join(dir, "missing.css"))
+	assert.NotNil(t, err)
+}
@@ -1,13 +1,15 @@
 package pkg
 
 import (
+	"path"
+	"path/filepath"
 	"strings"
 	"sync"
 )
 
 type CssPath struct {
 	Output string
-	Input  string // absolute path
+	Input  string // relative to the working directory
 }
 
 func (c *CssPath) Filename() string {
@@ -15,27 +17,55 @@ func (c *CssPath) Filename() string {
 	return rf[len(rf)-1]
 }
 
+// URLPath returns the URL path the css file is served at. That is the path of
+// the input with the extension of css, such as "/main.css" for "main.scss",
+// or the path of the output relative to Config.TmpDir if the input is
+// unknown.
+func (c *CssPath) URLPath() string {
+	p := CssOutputName(c.Input)
+	if p == "" {
+		rel, err := filepath.Rel(Config.TmpDir, c.Output)
+		if err != nil {
+			rel = c.Filename()
+		}
+		p = rel
+	}
+	return "/" + strings.TrimPrefix(path.Clean(filepath.ToSlash(p)), "/")
+}
+
+// CssFiles maps URL paths to the built css files.
 type CssFiles struct {
 	mu    sync.Mutex
-	paths []*CssPath
+	paths map[string]*CssPath
 }
 
+// Add adds or replaces the css file served at path.URLPath().
 func (c *CssFiles) Add(path *CssPath) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	c.paths = append(c.paths, path)
+	if c.paths == nil {
+		c.paths = map[string]*CssPath{}
+	}
+	c.paths[path.URLPath()] = path
+}
+
+// Remove removes the css file served at the URL path s.
+func (c *CssFiles) Remove(s string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.paths, path.Clean("/"+s))
 }
 
+// GetOutput returns the output served at the URL path s, or an empty string if
+// there is none.
 func (c *CssFiles) GetOutput(s string) string {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	for _, cs := range c.paths {
-		if strings.Contains(cs.Output, s) {
-			return cs.Output
-		}
+	if cs, ok := c.paths[path.Clean("/"+s)]; ok {
+		return cs.Output
 	}
-
 	return ""
 }
@@ -0,0 +1,28 @@
+package pkg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCssFiles(t *testing.T) {
+	*Config = DefaultConfig()
+
+	var c CssFiles
+	c.Add(&CssPath{Input: "a/styles.css", Output: "tmp/a/styles.css"})
+	c.Add(&CssPath{Input: "b/styles.css", Output: "tmp/b/styles.css"})
+	c.Add(&CssPath{Output: "tmp/utils.css"})
+	c.Add(&CssPath{Input: "sass/main.scss", Output: "tmp/sass/main.css"})
+
+	assert.Equal(t, "tmp/a/styles.css", c.GetOutput("/a/styles.css"))
+	assert.Equal(t, "tmp/b/styles.css", c.GetOutput("/b/styles.css"))
+	assert.Equal(t, "tmp/utils.css", c.GetOutput("/utils.css"))
+	assert.Equal(t, "tmp/sass/main.css", c.GetOutput("/sass/main.css"))
+	assert.Equal(t, "", c.GetOutput("/s.css"))
+	assert.Equal(t, "", c.GetOutput("/styles.css"))
+
+	c.Remove("/a/styles.css")
+	assert.Equal(t, "", c.GetOutput("/a/styles.css"))
+	assert.Equal(t, "tmp/b/styles.css", c.GetOutput("/b/styles.css"))
+}
@@ -0,0 +1,198 @@
+package pkg
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	// DefaultExportDir is the directory wasmserve export writes the site to.
+	DefaultExportDir = "dist"
+	// DefaultExportAssets are the globs of the public assets that are exported.
+	DefaultExportAssets = []string{"assets/**", "static/**", "public/**", "favicon.ico", "robots.txt"}
+)
+
+// ExportMarkerFile is the file written to the root of an exported site. An
+// export only replaces a directory that is missing, empty, or has this file.
+const ExportMarkerFile = ".wasmserve-export"
+
+// cfgExport is the [export] section.
+type cfgExport struct {
+	// Dir is the directory the site is written to. Defaults to
+	// DefaultExportDir.
+	Dir string `toml:"dir,omitempty"`
+	// BasePath is the URL path the site is hosted under, such as "/repo/" for
+	// a GitHub Pages project site. Defaults to "/".
+	BasePath string `toml:"base_path,omitempty"`
+	// Assets lists the globs of the public files that are copied with their
+	// paths. Defaults to DefaultExportAssets.
+	Assets []string `toml:"assets,omitempty"`
+	// Precompress writes brotli and gzip siblings of the wasm, js and css
+	// files, such as main.wasm.br. Defaults to true.
+	Precompress *bool `toml:"precompress,omitempty"`
+}
+
+// ExportDir returns the directory the site is exported to.
+func (c *config) ExportDir() string {
+	if c.Export.Dir != "" {
+		return c.Export.Dir
+	}
+	return DefaultExportDir
+}
+
+// ExportAssets returns the globs of the exported public files.
+func (c *config) ExportAssets() []string {
+	if c.Export.Assets != nil {
+		return c.Export.Assets
+	}
+	return DefaultExportAssets
+}
+
+// ExportPrecompress reports whether the exported wasm, js and css files get
+// precompressed siblings.
+func (c *config) ExportPrecompress() bool {
+	return c.Export.Precompress == nil || *c.Export.Precompress
+}
+
+// CanReplaceExportDir reports whether an export can replace dir: it doesn't
+// exist, it is empty, or it has the ExportMarkerFile of a previous export.
+func CanReplaceExportDir(dir string) (bool, error) {
+	f, err := os.Open(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	if _, err := f.Readdirnames(1); err == io.EOF {
+		return true, nil
+	} else if err != nil {
+		return false, err
+	}
+	if _, err := os.Stat(filepath.Join(dir, ExportMarkerFile)); err == nil {
+		return true, nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return false, err
+	}
+	return false, nil
+}
+
+// GlobDir returns the directory that holds every file matching the glob
+// pattern: its elements before the first one with a wildcard, such as
+// "public" for "public/**". It is "." for the patterns that match anywhere.
+func GlobDir(pattern string) string {
+	if !strings.Contains(pattern, "/") {
+		return "."
+	}
+	elems := strings.Split(pattern, "/")
+	var dir []string
+	for _, e := range elems[:len(elems)-1] {
+		if strings.ContainsAny(e, "*?[\\") {
+			break
+		}
+		dir = append(dir, e)
+	}
+	if len(dir) == 0 {
+		return "."
+	}
+	return strings.Join(dir, "/")
+}
+
+// NormalizeBasePath returns the base path p with a leading and a trailing
+// slash, such as "/repo/" for "repo".
+func NormalizeBasePath(p string) string {
+	p = strings.Trim(strings.TrimSpace(p), "/")
+	if p == "" {
+		return "/"
+	}
+	return "/" + p + "/"
+}
+
+var (
+	htmlRootURLRe = regexp.MustCompile(`(?i)(\s(?:src|href|action|poster)\s*=\s*["'])/([^/])`)
+	htmlHeadRe    = regexp.MustCompile(`(?i)<head(\s[^>]*)?>`)
+	htmlBaseRe    = regexp.MustCompile(`(?i)<base\s`)
+	htmlDoctypeRe = regexp.MustCompile(`(?i)^\s*<!doctype[^>]*>`)
+)
+
+// RebaseHTML returns the HTML page src for a site hosted under the base path
+// base. The root-relative src and href attributes, such as "/app.css", are
+// prefixed with base, and a <base> element makes the relative URLs resolve
+// from base on every route.
+func RebaseHTML(src []byte, base string) []byte {
+	base = NormalizeBasePath(base)
+	if base == "/" {
+		return src
+	}
+	return AddHTMLBase(htmlRootURLRe.ReplaceAll(src, []byte("${1}"+base+"${2}")), base)
+}
+
+// AddHTMLBase returns the HTML page src with a <base> element for the base
+// path base, unless it has one, so that its relative URLs resolve from base
+// wherever the page is served, such as a 404 page served for any path.
+func AddHTMLBase(src []byte, base string) []byte {
+	out := src
+	if htmlBaseRe.Match(out) {
+		return out
+	}
+	tag := `<base href="` + NormalizeBasePath(base) + `">`
+	insert := func(at int, s string) []byte {
+		return append(append(append([]byte{}, out[:at]...), s...), out[at:]...)
+	}
+	if loc := htmlHeadRe.FindIndex(out); loc != nil {
+		return insert(loc[1], tag)
+	}
+	if loc := htmlDoctypeRe.FindIndex(out); loc != nil {
+		return insert(loc[1], "\n"+tag)
+	}
+	return insert(0, tag+"\n")
+}
+
+// RebaseCss returns the stylesheet src with its root-relative url()s, such as
+// url(/fonts/a.woff2), prefixed with the base path base.
+func RebaseCss(src []byte, base string) []byte {
+	base = NormalizeBasePath(base)
+	if base == "/" {
+		return src
+	}
+	var out bytes.Buffer
+	for i := 0; i < len(src); {
+		switch c := src[i]; {
+		case c == '/' && i+1 < len(src) && src[i+1] == '*':
+			end := cssCommentEnd(src, i)
+			out.Write(src[i:end])
+			i = end
+		case c == '"' || c == '\'':
+			end := cssStringEnd(src, i)
+			out.Write(src[i:end])
+			i = end
+		case hasCssKeyword(src[i:], "url(") && (i == 0 || !isCssIdent(src[i-1])):
+			end := matchingParen(string(src[i:]), len("url"))
+			if end < 0 {
+				out.Write(src[i:])
+				return out.Bytes()
+			}
+			arg := strings.TrimSpace(string(src[i+len("url(") : i+end-1]))
+			quote := ""
+			if len(arg) >= 2 && (arg[0] == '"' || arg[0] == '\'') && arg[len(arg)-1] == arg[0] {
+				quote = arg[:1]
+				arg = arg[1 : len(arg)-1]
+			}
+			if strings.HasPrefix(arg, "/") && !strings.HasPrefix(arg, "//") {
+				arg = base + strings.TrimPrefix(arg, "/")
+			}
+			out.WriteString("url(" + quote + arg + quote + ")")
+			i += end
+		default:
+			out.WriteByte(c)
+			i++
+		}
+	}
+	return out.Bytes()
+}
@@ -0,0 +1,82 @@
+package pkg
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeBasePath(t *testing.T) {
+	assert.Equal(t, "/", NormalizeBasePath(""))
+	assert.Equal(t, "/", NormalizeBasePath("/"))
+	assert.Equal(t, "/repo/", NormalizeBasePath("repo"))
+	assert.Equal(t, "/a/b/", NormalizeBasePath("/a/b"))
+}
+
+func TestRebaseHTML(t *testing.T) {
+	src := `<!DOCTYPE html>
+<html><head>
+<link rel="stylesheet" href="/css/main.css">
+<script src="wasm_exec.js"></script>
+<a href="//cdn.example.com/x">cdn</a> <a href="/">home</a>
+</head></html>`
+	assert.Equal(t, `<!DOCTYPE html>
+<html><head><base href="/repo/">
+<link rel="stylesheet" href="/repo/css/main.css">
+<script src="wasm_exec.js"></script>
+<a href="//cdn.example.com/x">cdn</a> <a href="/repo/">home</a>
+</head></html>`, string(RebaseHTML([]byte(src), "repo")))
+
+	assert.Equal(t, "<!DOCTYPE html>\n<base href=\"/repo/\">\n<script src=\"/repo/a.js\"></script>",
+		string(RebaseHTML([]byte("<!DOCTYPE html>\n<script src=\"/a.js\"></script>"), "/repo/")))
+	assert.Equal(t, `<head><base href="/repo/x/"><link href="/repo/a.css">`,
+		string(RebaseHTML([]byte(`<head><base href="/x/"><link href="/a.css">`), "/repo/")))
+	assert.Equal(t, src, string(RebaseHTML([]byte(src), "/")))
+}
+
+func TestAddHTMLBase(t *testing.T) {
+	assert.Equal(t, "<head><base href=\"/\"><script src=\"a.js\"></script>",
+		string(AddHTMLBase([]byte("<head><script src=\"a.js\"></script>"), "/")))
+	assert.Equal(t, "<!DOCTYPE html>\n<base href=\"/repo/\">\n<p>",
+		string(AddHTMLBase([]byte("<!DOCTYPE html>\n<p>"), "repo")))
+	assert.Equal(t, `<base href="/x/"><p>`, string(AddHTMLBase([]byte(`<base href="/x/"><p>`), "/")))
+}
+
+func TestRebaseCss(t *testing.T) {
+	src := `@font-face{src:url(/fonts/a.woff2)}a{background:url("/img/b.png")}b{background:url(c.png)}/* url(/d.png) */i{background:url(//cdn/e.png)}`
+	assert.Equal(t, `@font-face{src:url(/repo/fonts/a.woff2)}a{background:url("/repo/img/b.png")}b{background:url(c.png)}/* url(/d.png) */i{background:url(//cdn/e.png)}`,
+		string(RebaseCss([]byte(src), "/repo/")))
+	assert.Equal(t, src, string(RebaseCss([]byte(src), "")))
+}
+
+func TestCanReplaceExportDir(t *testing.T) {
+	dir := t.TempDir()
+	ok, err := CanReplaceExportDir(filepath.Join(dir, "missing"))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	ok, err = CanReplaceExportDir(dir)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "main.go"), nil, 0644))
+	ok, err = CanReplaceExportDir(dir)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, ExportMarkerFile), nil, 0644))
+	ok, err = CanReplaceExportDir(dir)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+}
+
+func TestGlobDir(t *testing.T) {
+	assert.Equal(t, "public", GlobDir("public/**"))
+	assert.Equal(t, "src/web", GlobDir("src/web/**/*.go"))
+	assert.Equal(t, "assets", GlobDir("assets/*.png"))
+	assert.Equal(t, ".", GlobDir("**/*.go"))
+	assert.Equal(t, ".", GlobDir("favicon.ico"))
+	assert.Equal(t, ".", GlobDir("*/x.css"))
+}
@@ -0,0 +1,31 @@
+package pkg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchGlob(t *testing.T) {
+	cases := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "sub/dir/main.go", true},
+		{"*.go", "main.css", false},
+		{"**/*.go", "main.go", true},
+		{"**/*.go", "a/b/main.go", true},
+		{"tmp/**", "tmp", true},
+		{"tmp/**", "tmp/main.wasm", true},
+		{"tmp/**", "src/tmp/main.wasm", false},
+		{"**/node_modules/**", "frontend/node_modules/x/y.css", true},
+		{"src/*.css", "src/a.css", true},
+		{"src/*.css", "src/b/a.css", false},
+		{"src/**/a.css", "src/a.css", true},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.want, MatchGlob(c.pattern, c.name), "%s %s", c.pattern, c.name)
+	}
+}
@@ -0,0 +1,87 @@
+package pkg
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Formats of the JavaScript bundles.
+const (
+	JsFormatESM  = "esm"
+	JsFormatIIFE = "iife"
+	JsFormatCJS  = "cjs"
+)
+
+// cfgJs is the [js] section: the JavaScript and TypeScript entry points that
+// are bundled with esbuild, without Node.
+type cfgJs struct {
+	// Entries lists the entry points, such as "js/worklet.ts". Each is bundled
+	// with its imports to the same path in tmp_dir, with the extension of js.
+	Entries []string `toml:"entries,omitempty"`
+	// Format is JsFormatESM, JsFormatIIFE or JsFormatCJS. Defaults to
+	// JsFormatESM.
+	Format string `toml:"format,omitempty"`
+	// Target is the JavaScript version of the output, such as "es2020".
+	// Defaults to "esnext".
+	Target string `toml:"target,omitempty"`
+	// Minify minifies the bundles.
+	Minify bool `toml:"minify,omitempty"`
+	// Sourcemap writes a source map next to each bundle. Defaults to true.
+	Sourcemap *bool `toml:"sourcemap,omitempty"`
+	// External lists the imports that are left to the browser, such as
+	// "https://*".
+	External []string `toml:"external,omitempty"`
+}
+
+// JsExts are the extensions of the JavaScript and TypeScript files.
+var JsExts = []string{".js", ".mjs", ".cjs", ".jsx", ".ts", ".mts", ".cts", ".tsx"}
+
+// IsJsFile reports whether name is a JavaScript or TypeScript file.
+func IsJsFile(name string) bool {
+	ext := filepath.Ext(name)
+	for _, e := range JsExts {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// JsOutputName returns the name of the bundle of the entry point name, such as
+// "js/db.js" for "js/db.ts".
+func JsOutputName(name string) string {
+	return strings.TrimSuffix(name, filepath.Ext(name)) + ".js"
+}
+
+// JsEnabled reports whether there are entry points to bundle.
+func (c *config) JsEnabled() bool {
+	return len(c.Js.Entries) > 0
+}
+
+// JsFormat returns the format of the bundles.
+func (c *config) JsFormat() string {
+	if c.Js.Format == "" {
+		return JsFormatESM
+	}
+	return c.Js.Format
+}
+
+// JsSourcemap reports whether the bundles have source maps.
+func (c *config) JsSourcemap() bool {
+	return c.Js.Sourcemap == nil || *c.Js.Sourcemap
+}
+
+func validateJs(js cfgJs) error {
+	switch js.Format {
+	case "", JsFormatESM, JsFormatIIFE, JsFormatCJS:
+	default:
+		return fmt.Errorf("js.format: unknown format %q (want %q, %q or %q)", js.Format, JsFormatESM, JsFormatIIFE, JsFormatCJS)
+	}
+	for i, e := range js.Entries {
+		if !IsJsFile(e) {
+			return fmt.Errorf("js.entries[%d]: %s is not a JavaScript or TypeScript file", i, e)
+		}
+	}
+	return nil
+}
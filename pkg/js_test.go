@@ -0,0 +1,45 @@
+package pkg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJsNames(t *testing.T) {
+	assert.True(t, IsJsFile("js/db.ts"))
+	assert.True(t, IsJsFile("js/app.tsx"))
+	assert.True(t, IsJsFile("wasm_exec.js"))
+	assert.False(t, IsJsFile("js/types.json"))
+
+	assert.Equal(t, "js/db.js", JsOutputName("js/db.ts"))
+	assert.Equal(t, "js/app.js", JsOutputName("js/app.tsx"))
+	assert.Equal(t, "js/util.js", JsOutputName("js/util.js"))
+}
+
+func TestJsConfig(t *testing.T) {
+	conf, err := ReadConfig(writeConfig(t, `
+[watch]
+include = ["**/*.go"]
+
+[js]
+entries = ["js/worklet.ts"]
+`))
+	assert.Nil(t, err)
+	assert.True(t, conf.JsEnabled())
+	assert.Equal(t, JsFormatESM, conf.JsFormat())
+	assert.True(t, conf.JsSourcemap())
+	assert.Contains(t, conf.WatchInclude(), "**/*.ts")
+	assert.Contains(t, conf.WatchInclude(), "**/*.cts")
+	assert.Equal(t, []string{"**/*.go"}, conf.Watch.Include)
+
+	conf, err = ReadConfig(writeConfig(t, ``))
+	assert.Nil(t, err)
+	assert.False(t, conf.JsEnabled())
+	assert.NotContains(t, conf.WatchInclude(), "**/*.ts")
+
+	_, err = ReadConfig(writeConfig(t, "[js]\nentries = [\"js/a.ts\"]\nformat = \"umd\""))
+	assert.NotNil(t, err)
+	_, err = ReadConfig(writeConfig(t, "[js]\nentries = [\"js/a.css\"]"))
+	assert.NotNil(t, err)
+}
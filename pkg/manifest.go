@@ -0,0 +1,103 @@
+package pkg
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ManifestFile is the name of the manifest in tmp_dir and in the export
+// directory.
+const ManifestFile = "manifest.json"
+
+// hashLen is the length of the hashes in the file names.
+const hashLen = 10
+
+// Manifest maps the URL paths of the outputs, such as "css/main.css", to the
+// URL paths of their content-hashed copies, such as "css/main.3f2a1b9c0d.css".
+// The paths have no leading slash.
+type Manifest map[string]string
+
+// HashedName returns name with the hash of data before its extension, such as
+// "main.3f2a1b9c0d.wasm" for "main.wasm".
+func HashedName(name string, data []byte) string {
+	sum := sha256.Sum256(data)
+	h := hex.EncodeToString(sum[:])[:hashLen]
+	ext := path.Ext(name)
+	return strings.TrimSuffix(name, ext) + "." + h + ext
+}
+
+// ReadManifest reads the manifest at path. A missing file is an empty
+// manifest.
+func ReadManifest(path string) (Manifest, error) {
+	m := Manifest{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Write writes the manifest to a temporary file next to path and renames it
+// to path.
+func (m Manifest) Write(path string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(0644); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// Asset returns the hashed URL path of the output at the URL path name, or
+// name if it has no hashed copy. A leading slash is kept.
+func (m Manifest) Asset(name string) string {
+	slash := strings.HasPrefix(name, "/")
+	h, ok := m[strings.TrimPrefix(path.Clean("/"+name), "/")]
+	if !ok {
+		return name
+	}
+	if slash {
+		return "/" + h
+	}
+	return h
+}
+
+// IsHashed reports whether the URL path p is one of the hashed copies.
+func (m Manifest) IsHashed(p string) bool {
+	p = strings.TrimPrefix(path.Clean("/"+p), "/")
+	for _, h := range m {
+		if h == p {
+			return true
+		}
+	}
+	return false
+}
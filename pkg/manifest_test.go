@@ -0,0 +1,35 @@
+package pkg
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashedName(t *testing.T) {
+	a := HashedName("css/main.css", []byte("a{}"))
+	assert.Regexp(t, `^css/main\.[0-9a-f]{10}\.css$`, a)
+	assert.Equal(t, a, HashedName("css/main.css", []byte("a{}")))
+	assert.NotEqual(t, a, HashedName("css/main.css", []byte("b{}")))
+	assert.Regexp(t, `^main\.[0-9a-f]{10}\.wasm$`, HashedName("main.wasm", nil))
+}
+
+func TestManifest(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "tmp", ManifestFile)
+	m, err := ReadManifest(p)
+	assert.Nil(t, err)
+	assert.Empty(t, m)
+
+	m["main.wasm"] = "main.0123456789.wasm"
+	m["css/main.css"] = "css/main.abcdefabcd.css"
+	assert.Nil(t, m.Write(p))
+	m, err = ReadManifest(p)
+	assert.Nil(t, err)
+
+	assert.Equal(t, "main.0123456789.wasm", m.Asset("main.wasm"))
+	assert.Equal(t, "/css/main.abcdefabcd.css", m.Asset("/css/main.css"))
+	assert.Equal(t, "wasm_exec.js", m.Asset("wasm_exec.js"))
+	assert.True(t, m.IsHashed("/css/main.abcdefabcd.css"))
+	assert.False(t, m.IsHashed("/css/main.css"))
+}
@@ -0,0 +1,152 @@
+package pkg
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Names of the built-in profiles.
+const (
+	ProfileDev     = "dev"
+	ProfileRelease = "release"
+)
+
+var (
+	// DefaultWasmOptExec is the binaryen optimizer run by the profiles with
+	// wasm_opt.
+	DefaultWasmOptExec = Command{"wasm-opt"}
+	// DefaultWasmOptArgs optimize for size. The features are the ones the Go
+	// compiler emits, which wasm-opt rejects unless they are enabled.
+	DefaultWasmOptArgs = Command{"-Oz", "--enable-bulk-memory", "--enable-nontrapping-float-to-int", "--enable-sign-ext"}
+)
+
+// cfgProfile is a [profile.<name>] table. The unset settings keep the defaults
+// of the built-in profile of the same name, if there is one.
+type cfgProfile struct {
+	// Trimpath removes the file system paths from the wasm.
+	Trimpath *bool `toml:"trimpath,omitempty"`
+	// Ldflags are the -ldflags of go build, such as "-s -w".
+	Ldflags *string `toml:"ldflags,omitempty"`
+	// GoFlags are more flags of go build, such as "-gcflags=-B".
+	GoFlags Command `toml:"go_flags,omitempty"`
+	// WasmOpt runs wasm-opt on the wasm if it is installed.
+	WasmOpt *bool `toml:"wasm_opt,omitempty"`
+	// WasmOptArgs are the arguments of wasm-opt besides the input and output
+	// files. Defaults to DefaultWasmOptArgs.
+	WasmOptArgs Command `toml:"wasm_opt_args,omitempty"`
+	// Minify minifies the css and js outputs, unless the Tailwind options of
+	// an entry set minify.
+	Minify *bool `toml:"minify,omitempty"`
+}
+
+// BuildProfile is the resolved profile a build uses.
+type BuildProfile struct {
+	Name        string
+	Trimpath    bool
+	Ldflags     string
+	GoFlags     []string
+	WasmOpt     bool
+	WasmOptArgs []string
+	Minify      bool
+}
+
+// DefaultProfiles are the built-in profiles. dev builds as go build does, and
+// release optimizes the outputs for size.
+func DefaultProfiles() map[string]BuildProfile {
+	return map[string]BuildProfile{
+		ProfileDev: {Name: ProfileDev},
+		ProfileRelease: {
+			Name:     ProfileRelease,
+			Trimpath: true,
+			Ldflags:  "-s -w",
+			WasmOpt:  true,
+			Minify:   true,
+		},
+	}
+}
+
+// UseProfile makes the builds use the profile name, which is a built-in
+// profile or a [profile.<name>] table.
+func (c *config) UseProfile(name string) error {
+	p, err := c.resolveProfile(name)
+	if err != nil {
+		return err
+	}
+	c.profile = &p
+	return nil
+}
+
+// BuildProfile returns the profile of the builds, which is dev unless
+// UseProfile selected another.
+func (c *config) BuildProfile() BuildProfile {
+	if c.profile != nil {
+		return *c.profile
+	}
+	p, _ := c.resolveProfile(ProfileDev)
+	return p
+}
+
+func (c *config) resolveProfile(name string) (BuildProfile, error) {
+	p, ok := DefaultProfiles()[name]
+	t, defined := c.Profiles[name]
+	if !ok && !defined {
+		return BuildProfile{}, fmt.Errorf("unknown profile %q (want one of %s)", name, strings.Join(c.ProfileNames(), ", "))
+	}
+	p.Name = name
+	if t.Trimpath != nil {
+		p.Trimpath = *t.Trimpath
+	}
+	if t.Ldflags != nil {
+		p.Ldflags = *t.Ldflags
+	}
+	if t.GoFlags != nil {
+		p.GoFlags = t.GoFlags.Args()
+	}
+	if t.WasmOpt != nil {
+		p.WasmOpt = *t.WasmOpt
+	}
+	p.WasmOptArgs = DefaultWasmOptArgs.Args()
+	if t.WasmOptArgs != nil {
+		p.WasmOptArgs = t.WasmOptArgs.Args()
+	}
+	if t.Minify != nil {
+		p.Minify = *t.Minify
+	}
+	return p, nil
+}
+
+// ProfileNames returns the names of the built-in and the configured profiles.
+func (c *config) ProfileNames() []string {
+	var names []string
+	for n := range DefaultProfiles() {
+		names = append(names, n)
+	}
+	for n := range c.Profiles {
+		if _, ok := DefaultProfiles()[n]; !ok {
+			names = append(names, n)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// WasmOptCommand returns the command of wasm-opt.
+func (c *config) WasmOptCommand() []string {
+	if len(c.WasmOptExec) > 0 {
+		return c.WasmOptExec.Args()
+	}
+	return DefaultWasmOptExec.Args()
+}
+
+// CssMinifyEnabled reports whether the css files built without Tailwind are
+// minified.
+func (c *config) CssMinifyEnabled() bool {
+	return c.CssMinify || c.BuildProfile().Minify
+}
+
+// JsMinifyEnabled reports whether the bundles of the [js] entries are
+// minified.
+func (c *config) JsMinifyEnabled() bool {
+	return c.Js.Minify || c.BuildProfile().Minify
+}
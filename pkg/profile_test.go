@@ -0,0 +1,81 @@
+package pkg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuiltinProfiles(t *testing.T) {
+	conf, err := ReadConfig(writeConfig(t, ``))
+	assert.Nil(t, err)
+	p := conf.BuildProfile()
+	assert.Equal(t, ProfileDev, p.Name)
+	assert.False(t, p.Trimpath)
+	assert.Equal(t, "", p.Ldflags)
+	assert.False(t, p.WasmOpt)
+	assert.False(t, conf.CssMinifyEnabled())
+	assert.Nil(t, conf.TailwindOptionsFor("css/main.css").Minify)
+
+	assert.Nil(t, conf.UseProfile(ProfileRelease))
+	p = conf.BuildProfile()
+	assert.Equal(t, ProfileRelease, p.Name)
+	assert.True(t, p.Trimpath)
+	assert.Equal(t, "-s -w", p.Ldflags)
+	assert.True(t, p.WasmOpt)
+	assert.Equal(t, []string(DefaultWasmOptArgs), p.WasmOptArgs)
+	assert.True(t, conf.CssMinifyEnabled())
+	assert.True(t, conf.JsMinifyEnabled())
+	assert.Equal(t, []string{"wasm-opt"}, conf.WasmOptCommand())
+	if m := conf.TailwindOptionsFor("css/main.css").Minify; assert.NotNil(t, m) {
+		assert.True(t, *m)
+	}
+
+	err = conf.UseProfile("staging")
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "dev, release")
+	}
+}
+
+func TestProfileTables(t *testing.T) {
+	conf, err := ReadConfig(writeConfig(t, `
+wasm_opt_exec = "/opt/binaryen/bin/wasm-opt"
+
+[profile.dev]
+go_flags = "-gcflags=all=-N"
+
+[profile.release]
+ldflags = "-s -w -X main.version=1.0"
+wasm_opt_args = ["-O3"]
+
+[profile.staging]
+trimpath = true
+minify = true
+
+[tailwind.entries."css/debug.css"]
+minify = false
+`))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"-gcflags=all=-N"}, conf.BuildProfile().GoFlags)
+	assert.Equal(t, []string{"/opt/binaryen/bin/wasm-opt"}, conf.WasmOptCommand())
+	assert.Equal(t, []string{"dev", "release", "staging"}, conf.ProfileNames())
+
+	assert.Nil(t, conf.UseProfile(ProfileRelease))
+	p := conf.BuildProfile()
+	assert.True(t, p.Trimpath)
+	assert.Equal(t, "-s -w -X main.version=1.0", p.Ldflags)
+	assert.Equal(t, []string{"-O3"}, p.WasmOptArgs)
+	assert.Nil(t, p.GoFlags)
+
+	assert.Nil(t, conf.UseProfile("staging"))
+	p = conf.BuildProfile()
+	assert.Equal(t, "staging", p.Name)
+	assert.True(t, p.Trimpath)
+	assert.Equal(t, "", p.Ldflags)
+	assert.False(t, p.WasmOpt)
+	assert.True(t, p.Minify)
+	// The options of an entry win over the profile.
+	if m := conf.TailwindOptionsFor("css/debug.css").Minify; assert.NotNil(t, m) {
+		assert.False(t, *m)
+	}
+}
@@ -0,0 +1,77 @@
+package pkg
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var sizeUnits = []struct {
+	suffix string
+	bytes  float64
+}{
+	{"KIB", 1 << 10},
+	{"MIB", 1 << 20},
+	{"GIB", 1 << 30},
+	{"KB", 1 << 10},
+	{"MB", 1 << 20},
+	{"GB", 1 << 30},
+	{"K", 1 << 10},
+	{"M", 1 << 20},
+	{"G", 1 << 30},
+	{"B", 1},
+}
+
+// ParseSize parses a size such as "512", "50KB" or "1.5MB". The units are
+// powers of 1024.
+func ParseSize(s string) (int64, error) {
+	t := strings.ToUpper(strings.TrimSpace(s))
+	mult := 1.0
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(t, u.suffix) {
+			t = strings.TrimSpace(strings.TrimSuffix(t, u.suffix))
+			mult = u.bytes
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(t, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(n * mult), nil
+}
+
+// FormatSize formats a number of bytes for humans, such as "1.5MB".
+func FormatSize(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return strconv.FormatFloat(float64(n)/(1<<30), 'f', 1, 64) + "GB"
+	case n >= 1<<20:
+		return strconv.FormatFloat(float64(n)/(1<<20), 'f', 1, 64) + "MB"
+	case n >= 1<<10:
+		return strconv.FormatFloat(float64(n)/(1<<10), 'f', 1, 64) + "KB"
+	}
+	return strconv.FormatInt(n, 10) + "B"
+}
+
+// FormatSizeChange formats the sizes before and after a step, such as
+// "9.8MB -> 7.1MB (-27.6%)".
+func FormatSizeChange(before, after int64) string {
+	s := FormatSize(before) + " -> " + FormatSize(after)
+	if before > 0 {
+		sign := ""
+		if after >= before {
+			sign = "+"
+		}
+		s += " (" + sign + strconv.FormatFloat(float64(after-before)*100/float64(before), 'f', 1, 64) + "%)"
+	}
+	return s
+}
+
+// FormatSizeDelta formats a change of size with its sign, such as "+12.0KB".
+func FormatSizeDelta(n int64) string {
+	if n < 0 {
+		return "-" + FormatSize(-n)
+	}
+	return "+" + FormatSize(n)
+}
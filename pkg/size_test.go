@@ -0,0 +1,46 @@
+package pkg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSize(t *testing.T) {
+	cases := map[string]int64{
+		"512":   512,
+		"512B":  512,
+		"50KB":  50 << 10,
+		"1.5MB": 3 << 19,
+		"6 mb":  6 << 20,
+		"1GiB":  1 << 30,
+		" 2K ":  2 << 10,
+		"0.5GB": 1 << 29,
+	}
+	for in, want := range cases {
+		got, err := ParseSize(in)
+		assert.Nil(t, err, in)
+		assert.Equal(t, want, got, in)
+	}
+
+	for _, in := range []string{"", "MB", "-1MB", "1TB", "big"} {
+		_, err := ParseSize(in)
+		assert.NotNil(t, err, in)
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	assert.Equal(t, "512B", FormatSize(512))
+	assert.Equal(t, "50.0KB", FormatSize(50<<10))
+	assert.Equal(t, "1.5MB", FormatSize(3<<19))
+}
+
+func TestFormatSizeChange(t *testing.T) {
+	assert.Equal(t, "10.0MB -> 7.5MB (-25.0%)", FormatSizeChange(10<<20, 15<<19))
+	assert.Equal(t, "1.0KB -> 2.0KB (+100.0%)", FormatSizeChange(1<<10, 2<<10))
+	assert.Equal(t, "0B -> 10B", FormatSizeChange(0, 10))
+
+	assert.Equal(t, "+12.0KB", FormatSizeDelta(12<<10))
+	assert.Equal(t, "-512B", FormatSizeDelta(-512))
+	assert.Equal(t, "+0B", FormatSizeDelta(0))
+}
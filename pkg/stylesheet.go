@@ -0,0 +1,186 @@
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Names of the stylesheet processors, for the run lists of css_processors.
+const (
+	// CssProcessorTailwind builds the stylesheet with Tailwind.
+	CssProcessorTailwind = "tailwind"
+	// CssProcessorNative bundles the imports and minifies the stylesheet in Go.
+	CssProcessorNative = "native"
+	// CssProcessorCopy copies the stylesheet, minifying it if css_minify is set.
+	CssProcessorCopy = "copy"
+	// CssProcessorSass compiles Sass and SCSS with the dart-sass executable.
+	CssProcessorSass = "sass"
+	// CssProcessorPostcss runs the PostCSS CLI.
+	CssProcessorPostcss = "postcss"
+)
+
+var cssProcessorNames = []string{CssProcessorTailwind, CssProcessorNative, CssProcessorCopy, CssProcessorSass, CssProcessorPostcss}
+
+var (
+	// DefaultSassExec is the command of the sass processor.
+	DefaultSassExec = Command{"sass"}
+	// DefaultPostcssExec is the command of the postcss processor.
+	DefaultPostcssExec = Command{"npx", "postcss"}
+)
+
+// CssProcessorRule selects the processors of the stylesheets matching a glob:
+//
+//	[[css_processors]]
+//	files = "styles/**/*.scss"
+//	run = ["sass", "postcss"]
+//
+// Each processor reads the output of the one before it.
+type CssProcessorRule struct {
+	Files string   `toml:"files"`
+	Run   []string `toml:"run"`
+}
+
+// IsStylesheet reports whether name is a css, scss or sass file.
+func IsStylesheet(name string) bool {
+	switch filepath.Ext(name) {
+	case ".css", ".scss", ".sass":
+		return true
+	}
+	return false
+}
+
+// IsSassFile reports whether name is a scss or sass file.
+func IsSassFile(name string) bool {
+	switch filepath.Ext(name) {
+	case ".scss", ".sass":
+		return true
+	}
+	return false
+}
+
+// IsSassPartial reports whether name is a Sass partial, such as
+// "_variables.scss". Partials are only imported by other files, so they are
+// not built on their own.
+func IsSassPartial(name string) bool {
+	return IsSassFile(name) && strings.HasPrefix(filepath.Base(name), "_")
+}
+
+// CssOutputName returns the name of the css built from the stylesheet name,
+// such as "main.css" for "main.scss".
+func CssOutputName(name string) string {
+	if IsSassFile(name) {
+		return strings.TrimSuffix(name, filepath.Ext(name)) + ".css"
+	}
+	return name
+}
+
+// CssProcessorsFor returns the processors of the stylesheet input: the run
+// list of the first css_processors rule matching it, or the sass processor for
+// Sass files. It returns nil for the other css files, which are built by
+// Tailwind or the css_pipeline.
+func (c *config) CssProcessorsFor(input string) []string {
+	p := filepath.ToSlash(filepath.Clean(input))
+	for _, r := range c.CssProcessors {
+		if MatchGlob(path.Clean(filepath.ToSlash(r.Files)), p) {
+			return r.Run
+		}
+	}
+	if IsSassFile(input) {
+		return []string{CssProcessorSass}
+	}
+	return nil
+}
+
+// CssSourceMapsEnabled reports whether the source maps of the processors are
+// kept next to their outputs. Defaults to true.
+func (c *config) CssSourceMapsEnabled() bool {
+	return c.CssSourceMaps == nil || *c.CssSourceMaps
+}
+
+// SassCommand returns the command of the sass processor.
+func (c *config) SassCommand() Command {
+	if len(c.SassExec) > 0 {
+		return c.SassExec
+	}
+	return DefaultSassExec
+}
+
+// PostcssCommand returns the command of the postcss processor.
+func (c *config) PostcssCommand() Command {
+	if len(c.PostcssExec) > 0 {
+		return c.PostcssExec
+	}
+	return DefaultPostcssExec
+}
+
+func validateCssProcessors(rules []CssProcessorRule) error {
+	for i, r := range rules {
+		if r.Files == "" {
+			return fmt.Errorf("css_processors[%d]: files is empty", i)
+		}
+		if len(r.Run) == 0 {
+			return fmt.Errorf("css_processors[%d]: run is empty", i)
+		}
+	run:
+		for _, name := range r.Run {
+			for _, n := range cssProcessorNames {
+				if name == n {
+					continue run
+				}
+			}
+			return fmt.Errorf("css_processors[%d]: unknown processor %q (want one of %s)", i, name, strings.Join(cssProcessorNames, ", "))
+		}
+	}
+	return nil
+}
+
+// RelocateSourceMap rewrites the source map data, written in the directory
+// from, to be read from the directory to. The relative sources, or the
+// relative sourceRoot if there is one, are resolved from the new directory.
+func RelocateSourceMap(data []byte, from, to string) ([]byte, error) {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	rel := func(src string) string {
+		if src == "" || isRemoteCssURL(src) || strings.HasPrefix(src, "/") {
+			return src
+		}
+		r, err := filepath.Rel(to, filepath.Join(from, filepath.FromSlash(src)))
+		if err != nil {
+			return src
+		}
+		return filepath.ToSlash(r)
+	}
+
+	var root string
+	if raw, ok := m["sourceRoot"]; ok {
+		if err := json.Unmarshal(raw, &root); err != nil {
+			return nil, fmt.Errorf("sourceRoot: %v", err)
+		}
+	}
+	if root != "" {
+		b, err := json.Marshal(rel(root))
+		if err != nil {
+			return nil, err
+		}
+		m["sourceRoot"] = b
+	} else if raw, ok := m["sources"]; ok {
+		var sources []string
+		if err := json.Unmarshal(raw, &sources); err != nil {
+			return nil, fmt.Errorf("sources: %v", err)
+		}
+		for i, s := range sources {
+			sources[i] = rel(s)
+		}
+		b, err := json.Marshal(sources)
+		if err != nil {
+			return nil, err
+		}
+		m["sources"] = b
+	}
+	return json.Marshal(m)
+}
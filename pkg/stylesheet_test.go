@@ -0,0 +1,83 @@
+package pkg
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStylesheetNames(t *testing.T) {
+	assert.True(t, IsStylesheet("css/main.css"))
+	assert.True(t, IsStylesheet("css/main.scss"))
+	assert.True(t, IsStylesheet("css/main.sass"))
+	assert.False(t, IsStylesheet("css/main.less"))
+
+	assert.True(t, IsSassPartial("css/_vars.scss"))
+	assert.False(t, IsSassPartial("css/main.scss"))
+	assert.False(t, IsSassPartial("css/_vars.css"))
+
+	assert.Equal(t, "css/main.css", CssOutputName("css/main.scss"))
+	assert.Equal(t, "css/main.css", CssOutputName("css/main.sass"))
+	assert.Equal(t, "css/main.css", CssOutputName("css/main.css"))
+}
+
+func TestCssProcessors(t *testing.T) {
+	conf, err := ReadConfig(writeConfig(t, `
+[[css_processors]]
+files = "legacy/**/*.css"
+run = ["postcss"]
+
+[[css_processors]]
+files = "**/*.scss"
+run = ["sass", "postcss"]
+`))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"postcss"}, conf.CssProcessorsFor("legacy/a/b.css"))
+	assert.Equal(t, []string{"sass", "postcss"}, conf.CssProcessorsFor("./css/main.scss"))
+	assert.Equal(t, []string{"sass"}, conf.CssProcessorsFor("css/main.sass"))
+	assert.Nil(t, conf.CssProcessorsFor("css/main.css"))
+	assert.True(t, conf.CssEnabled())
+	assert.True(t, conf.CssSourceMapsEnabled())
+	assert.Equal(t, DefaultSassExec, conf.SassCommand())
+
+	conf, err = ReadConfig(writeConfig(t, "css_source_maps = false\nsass_exec = \"npx sass --load-path=node_modules\""))
+	assert.Nil(t, err)
+	assert.False(t, conf.CssSourceMapsEnabled())
+	assert.Equal(t, Command{"npx", "sass", "--load-path=node_modules"}, conf.SassCommand())
+
+	_, err = ReadConfig(writeConfig(t, "[[css_processors]]\nfiles = \"*.css\"\nrun = [\"less\"]"))
+	assert.NotNil(t, err)
+	_, err = ReadConfig(writeConfig(t, "[[css_processors]]\nfiles = \"*.css\""))
+	assert.NotNil(t, err)
+}
+
+func TestRelocateSourceMap(t *testing.T) {
+	root := filepath.FromSlash("/project")
+	from := filepath.Join(root, "tmp", "css", ".main.css.123")
+	to := filepath.Join(root, "tmp", "css")
+
+	out, err := RelocateSourceMap([]byte(`{"version":3,"sources":["../../../css/main.scss","https://example.com/a.scss",""],"names":[],"mappings":"AAAA","file":"main.css"}`), from, to)
+	assert.Nil(t, err)
+	var m struct {
+		Sources []string `json:"sources"`
+		File    string   `json:"file"`
+	}
+	assert.Nil(t, json.Unmarshal(out, &m))
+	assert.Equal(t, []string{"../../css/main.scss", "https://example.com/a.scss", ""}, m.Sources)
+	assert.Equal(t, "main.css", m.File)
+
+	out, err = RelocateSourceMap([]byte(`{"version":3,"sourceRoot":"../../../css","sources":["main.scss"]}`), from, to)
+	assert.Nil(t, err)
+	var r struct {
+		SourceRoot string   `json:"sourceRoot"`
+		Sources    []string `json:"sources"`
+	}
+	assert.Nil(t, json.Unmarshal(out, &r))
+	assert.Equal(t, "../../css", r.SourceRoot)
+	assert.Equal(t, []string{"main.scss"}, r.Sources)
+
+	_, err = RelocateSourceMap([]byte(`not json`), from, to)
+	assert.NotNil(t, err)
+}
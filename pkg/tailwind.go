@@ -0,0 +1,38 @@
+package pkg
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// TailwindVersion is the version of a Tailwind executable.
+type TailwindVersion struct {
+	Major, Minor, Patch int
+}
+
+func (v TailwindVersion) String() string {
+	return fmt.Sprintf("v%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// CssConfig reports whether the version is configured in the stylesheets
+// (`@theme`, `@source`) rather than in tailwind.config.js.
+func (v TailwindVersion) CssConfig() bool {
+	return v.Major >= 4
+}
+
+var tailwindVersionRe = regexp.MustCompile(`tailwindcss v?(\d+)\.(\d+)\.(\d+)`)
+
+// ParseTailwindVersion finds the version in the output of `tailwindcss --help`,
+// such as "tailwindcss v3.4.1" or "≈ tailwindcss v4.0.0".
+func ParseTailwindVersion(out []byte) (TailwindVersion, bool) {
+	m := tailwindVersionRe.FindSubmatch(out)
+	if m == nil {
+		return TailwindVersion{}, false
+	}
+	var n [3]int
+	for i := range n {
+		n[i], _ = strconv.Atoi(string(m[i+1]))
+	}
+	return TailwindVersion{Major: n[0], Minor: n[1], Patch: n[2]}, true
+}
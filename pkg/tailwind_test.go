@@ -0,0 +1,24 @@
+package pkg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTailwindVersion(t *testing.T) {
+	cases := map[string]TailwindVersion{
+		"\ntailwindcss v3.4.1\n\nUsage:\n   tailwindcss build [options]":     {3, 4, 1},
+		"≈ tailwindcss v4.0.14\n\nUsage:\n  tailwindcss [--input input.css]": {4, 0, 14},
+	}
+	for out, want := range cases {
+		got, ok := ParseTailwindVersion([]byte(out))
+		assert.True(t, ok, out)
+		assert.Equal(t, want, got, out)
+	}
+
+	_, ok := ParseTailwindVersion([]byte("npm ERR! could not determine executable to run"))
+	assert.False(t, ok)
+	assert.False(t, TailwindVersion{3, 4, 1}.CssConfig())
+	assert.True(t, TailwindVersion{4, 0, 0}.CssConfig())
+}
@@ -0,0 +1,235 @@
+package pkg
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	// DefaultTailwindDownloadURL is the releases page of Tailwind.
+	DefaultTailwindDownloadURL = "https://github.com/tailwindlabs/tailwindcss/releases"
+	// TailwindLatest is the tailwind_version that follows the latest release.
+	TailwindLatest = "latest"
+
+	tailwindChecksums = "sha256sums.txt"
+)
+
+var tailwindReleaseVersionRe = regexp.MustCompile(`^v\d+\.\d+\.\d+(-[0-9A-Za-z.]+)?$`)
+
+// NormalizeTailwindVersion returns the release tag of a tailwind_version, such
+// as "v4.1.3" for "4.1.3". An empty version is TailwindLatest.
+func NormalizeTailwindVersion(v string) (string, error) {
+	v = strings.TrimSpace(v)
+	if v == "" || v == TailwindLatest {
+		return TailwindLatest, nil
+	}
+	if !strings.HasPrefix(v, "v") {
+		v = "v" + v
+	}
+	if !tailwindReleaseVersionRe.MatchString(v) {
+		return "", fmt.Errorf("invalid Tailwind version %q (want %q or a version such as \"v4.1.3\")", v, TailwindLatest)
+	}
+	return v, nil
+}
+
+// TailwindExecutableName returns the name of the standalone Tailwind
+// executable for goos and goarch, such as "tailwindcss-macos-arm64".
+func TailwindExecutableName(goos, goarch string) (string, error) {
+	var osName, arch, ext string
+	switch goos {
+	case "darwin":
+		osName = "macos"
+	case "linux":
+		osName = "linux"
+	case "windows":
+		osName = "windows"
+		ext = ".exe"
+	default:
+		return "", fmt.Errorf("no Tailwind executable for %s/%s", goos, goarch)
+	}
+	switch goarch {
+	case "amd64":
+		arch = "x64"
+	case "arm64":
+		arch = "arm64"
+	case "arm":
+		// Only Tailwind v3 has 32-bit ARM executables, for Linux.
+		if goos != "linux" {
+			return "", fmt.Errorf("no Tailwind executable for %s/%s", goos, goarch)
+		}
+		arch = "armv7"
+	default:
+		return "", fmt.Errorf("no Tailwind executable for %s/%s", goos, goarch)
+	}
+	return "tailwindcss-" + osName + "-" + arch + ext, nil
+}
+
+// TailwindRelease downloads the standalone Tailwind executables to Dir, by
+// version and platform, and verifies them against the checksums of the
+// release.
+type TailwindRelease struct {
+	// BaseURL is the releases page, such as DefaultTailwindDownloadURL.
+	// Executables are downloaded from BaseURL/download/<version>/<name>.
+	BaseURL string
+	Dir     string
+	Client  *http.Client
+}
+
+func (r *TailwindRelease) client() *http.Client {
+	if r.Client != nil {
+		return r.Client
+	}
+	return http.DefaultClient
+}
+
+func (r *TailwindRelease) url(elem ...string) string {
+	return strings.TrimSuffix(r.BaseURL, "/") + "/" + path.Join(elem...)
+}
+
+// Latest returns the tag of the latest release, which BaseURL/latest
+// redirects to.
+func (r *TailwindRelease) Latest() (string, error) {
+	c := *r.client()
+	c.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	resp, err := c.Get(r.url("latest"))
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	loc := resp.Header.Get("Location")
+	if loc == "" {
+		return "", fmt.Errorf("%s: no redirect to the latest release (status %s)", r.url("latest"), resp.Status)
+	}
+	v := path.Base(loc)
+	if _, err := NormalizeTailwindVersion(v); err != nil || v == TailwindLatest {
+		return "", fmt.Errorf("%s: unexpected redirect to %s", r.url("latest"), loc)
+	}
+	return v, nil
+}
+
+// Path returns where the executable name of version is stored.
+func (r *TailwindRelease) Path(version, name string) string {
+	return filepath.Join(r.Dir, version, name)
+}
+
+// Install downloads the executable name of version unless it is already
+// stored, and returns its path.
+func (r *TailwindRelease) Install(version, name string) (string, error) {
+	p := r.Path(version, name)
+	if _, err := os.Stat(p); err == nil {
+		return p, nil
+	}
+	return p, r.download(version, name)
+}
+
+// Newest returns the newest version whose executable name is stored, or an
+// empty string if there is none.
+func (r *TailwindRelease) Newest(name string) (string, error) {
+	des, err := os.ReadDir(r.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	var newest string
+	var nv TailwindVersion
+	for _, de := range des {
+		v := de.Name()
+		if n, err := NormalizeTailwindVersion(v); err != nil || n != v || v == TailwindLatest {
+			continue
+		}
+		if _, err := os.Stat(r.Path(v, name)); err != nil {
+			continue
+		}
+		pv, _ := ParseTailwindVersion([]byte("tailwindcss " + v))
+		if newest == "" || pv.Major > nv.Major ||
+			pv.Major == nv.Major && (pv.Minor > nv.Minor || pv.Minor == nv.Minor && pv.Patch > nv.Patch) {
+			newest, nv = v, pv
+		}
+	}
+	return newest, nil
+}
+
+func (r *TailwindRelease) get(url string, w io.Writer) error {
+	resp, err := r.client().Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return fmt.Errorf("%s: %v", url, err)
+	}
+	return nil
+}
+
+func (r *TailwindRelease) checksum(version, name string) (string, error) {
+	var buf bytes.Buffer
+	url := r.url("download", version, tailwindChecksums)
+	if err := r.get(url, &buf); err != nil {
+		return "", err
+	}
+	// The lines are in the format of sha256sum: "<hash>  [./]<name>".
+	s := bufio.NewScanner(&buf)
+	for s.Scan() {
+		f := strings.Fields(s.Text())
+		if len(f) != 2 {
+			continue
+		}
+		if strings.TrimPrefix(strings.TrimPrefix(f[1], "*"), "./") == name {
+			return strings.ToLower(f[0]), nil
+		}
+	}
+	return "", fmt.Errorf("%s: no checksum for %s", url, name)
+}
+
+func (r *TailwindRelease) download(version, name string) error {
+	if version == TailwindLatest {
+		return errors.New("the latest version must be resolved before downloading")
+	}
+	want, err := r.checksum(version, name)
+	if err != nil {
+		return err
+	}
+
+	dst := r.Path(version, name)
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	out, err := os.CreateTemp(filepath.Dir(dst), "."+name+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(out.Name())
+	h := sha256.New()
+	if err := r.get(r.url("download", version, name), io.MultiWriter(out, h)); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != want {
+		return fmt.Errorf("%s %s: checksum mismatch: got %s, want %s", name, version, got, want)
+	}
+	if err := os.Chmod(out.Name(), 0755); err != nil {
+		return err
+	}
+	return os.Rename(out.Name(), dst)
+}
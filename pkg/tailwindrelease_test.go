@@ -0,0 +1,114 @@
+package pkg
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTailwindExecutableName(t *testing.T) {
+	cases := map[[2]string]string{
+		{"darwin", "arm64"}:  "tailwindcss-macos-arm64",
+		{"linux", "amd64"}:   "tailwindcss-linux-x64",
+		{"linux", "arm"}:     "tailwindcss-linux-armv7",
+		{"windows", "amd64"}: "tailwindcss-windows-x64.exe",
+	}
+	for p, want := range cases {
+		got, err := TailwindExecutableName(p[0], p[1])
+		assert.Nil(t, err, p)
+		assert.Equal(t, want, got, p)
+	}
+
+	for _, p := range [][2]string{{"freebsd", "amd64"}, {"linux", "386"}, {"windows", "arm"}} {
+		_, err := TailwindExecutableName(p[0], p[1])
+		assert.NotNil(t, err, p)
+	}
+}
+
+func TestNormalizeTailwindVersion(t *testing.T) {
+	for in, want := range map[string]string{"": "latest", "latest": "latest", "4.1.3": "v4.1.3", "v3.4.17": "v3.4.17", "v4.0.0-beta.1": "v4.0.0-beta.1"} {
+		got, err := NormalizeTailwindVersion(in)
+		assert.Nil(t, err, in)
+		assert.Equal(t, want, got, in)
+	}
+	_, err := NormalizeTailwindVersion("v4")
+	assert.NotNil(t, err)
+}
+
+func TestTailwindRelease(t *testing.T) {
+	const name = "tailwindcss-linux-x64"
+	bin := []byte("#!/bin/sh\necho tailwind\n")
+	sum := sha256.Sum256(bin)
+	downloads := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/latest", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/tag/v4.1.3", http.StatusFound)
+	})
+	mux.HandleFunc("/download/v4.1.3/sha256sums.txt", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s  ./%s\n", hex.EncodeToString(sum[:]), name)
+	})
+	mux.HandleFunc("/download/v4.1.3/"+name, func(w http.ResponseWriter, r *http.Request) {
+		downloads++
+		w.Write(bin)
+	})
+	mux.HandleFunc("/download/v4.0.0/sha256sums.txt", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%064x  ./%s\n", 0, name)
+	})
+	mux.HandleFunc("/download/v4.0.0/"+name, func(w http.ResponseWriter, r *http.Request) {
+		w.Write(bin)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	r := &TailwindRelease{BaseURL: srv.URL, Dir: t.TempDir()}
+	v, err := r.Latest()
+	assert.Nil(t, err)
+	assert.Equal(t, "v4.1.3", v)
+
+	p, err := r.Install(v, name)
+	assert.Nil(t, err)
+	assert.Equal(t, r.Path(v, name), p)
+	b, err := os.ReadFile(p)
+	assert.Nil(t, err)
+	assert.Equal(t, bin, b)
+	fi, err := os.Stat(p)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0755), fi.Mode().Perm())
+
+	// Installed executables are not downloaded again.
+	_, err = r.Install(v, name)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, downloads)
+
+	newest, err := r.Newest(name)
+	assert.Nil(t, err)
+	assert.Equal(t, "v4.1.3", newest)
+
+	// Executables that don't match their checksums are not stored.
+	_, err = r.Install("v4.0.0", name)
+	assert.NotNil(t, err)
+	_, err = os.Stat(r.Path("v4.0.0", name))
+	assert.True(t, os.IsNotExist(err))
+
+	_, err = r.Install("v9.9.9", name)
+	assert.NotNil(t, err)
+
+	// Versions without the executable are not installed.
+	assert.Nil(t, os.MkdirAll(filepath.Join(r.Dir, "v4.2.0"), 0755))
+	assert.Nil(t, os.MkdirAll(filepath.Dir(r.Path("v4.0.10", name)), 0755))
+	assert.Nil(t, os.WriteFile(r.Path("v4.0.10", name), bin, 0755))
+	newest, err = r.Newest(name)
+	assert.Nil(t, err)
+	assert.Equal(t, "v4.1.3", newest)
+
+	newest, err = (&TailwindRelease{Dir: filepath.Join(r.Dir, "missing")}).Newest(name)
+	assert.Nil(t, err)
+	assert.Equal(t, "", newest)
+}
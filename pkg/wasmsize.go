@@ -0,0 +1,775 @@
+package pkg
+
+import (
+	"bytes"
+	"debug/dwarf"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Kinds of the symbols of a SizeReport.
+const (
+	SizeKindCode  = "code"
+	SizeKindData  = "data"
+	SizeKindDebug = "debug"
+	// SizeKindOther is the rest of the sections, such as the type and
+	// import sections and the headers of the functions and data segments.
+	SizeKindOther = "other"
+)
+
+// Pseudo packages of the bytes that don't belong to a Go package.
+const (
+	// SizePackagePclntab holds the function tables of every package, which
+	// the runtime uses for stack traces and the garbage collector.
+	SizePackagePclntab = "(pclntab)"
+	// SizePackageData holds the data that is not attributed to a symbol.
+	SizePackageData = "(data)"
+	// SizePackageWasm holds the bytes of the module structure.
+	SizePackageWasm = "(wasm)"
+)
+
+// wasmSectionNames are the names of the known sections by their id.
+var wasmSectionNames = map[byte]string{
+	1:  "type",
+	2:  "import",
+	3:  "function",
+	4:  "table",
+	5:  "memory",
+	6:  "global",
+	7:  "export",
+	8:  "start",
+	9:  "element",
+	10: "code",
+	11: "data",
+	12: "datacount",
+	13: "tag",
+}
+
+// SizeReport breaks the size of a wasm file down by section, by Go package
+// and by symbol.
+type SizeReport struct {
+	Total    int64         `json:"total"`
+	Sections []SectionSize `json:"sections"`
+	Packages []PackageSize `json:"packages"`
+	Symbols  []SymbolSize  `json:"symbols"`
+}
+
+// SectionSize is the size of a section, including its header.
+type SectionSize struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+// PackageSize is the size of the symbols of a package.
+type PackageSize struct {
+	Name  string `json:"name"`
+	Size  int64  `json:"size"`
+	Code  int64  `json:"code"`
+	Data  int64  `json:"data"`
+	Debug int64  `json:"debug"`
+	Other int64  `json:"other"`
+}
+
+// SymbolSize is the size of a function, of a part of the data, or of the rest
+// of a section.
+type SymbolSize struct {
+	Name    string `json:"name"`
+	Package string `json:"package"`
+	Kind    string `json:"kind"`
+	Size    int64  `json:"size"`
+}
+
+// wasmSection is a section of a module. Start and End are the offsets of the
+// section in the file, including its id and size.
+type wasmSection struct {
+	ID      byte
+	Name    string
+	Start   int
+	End     int
+	Payload []byte
+}
+
+// wasmSegment is an active data segment, at the address Addr of the memory.
+type wasmSegment struct {
+	Addr uint64
+	Data []byte
+}
+
+type wasmReader struct {
+	b   []byte
+	pos int
+	err error
+}
+
+var errWasmTruncated = errors.New("truncated")
+
+func (r *wasmReader) fail(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+func (r *wasmReader) byte() byte {
+	if r.err != nil || r.pos >= len(r.b) {
+		r.fail(errWasmTruncated)
+		return 0
+	}
+	c := r.b[r.pos]
+	r.pos++
+	return c
+}
+
+func (r *wasmReader) uleb() uint64 {
+	var v uint64
+	for shift := uint(0); shift < 64; shift += 7 {
+		c := r.byte()
+		v |= uint64(c&0x7f) << shift
+		if c < 0x80 {
+			return v
+		}
+	}
+	r.fail(errors.New("invalid LEB128 number"))
+	return 0
+}
+
+func (r *wasmReader) sleb() int64 {
+	var v int64
+	shift := uint(0)
+	for shift < 64 {
+		c := r.byte()
+		v |= int64(c&0x7f) << shift
+		shift += 7
+		if c < 0x80 {
+			if shift < 64 && c&0x40 != 0 {
+				v |= -1 << shift
+			}
+			return v
+		}
+	}
+	r.fail(errors.New("invalid LEB128 number"))
+	return 0
+}
+
+func (r *wasmReader) bytes(n uint64) []byte {
+	if r.err != nil || n > uint64(len(r.b)-r.pos) {
+		r.fail(errWasmTruncated)
+		return nil
+	}
+	b := r.b[r.pos : r.pos+int(n)]
+	r.pos += int(n)
+	return b
+}
+
+func (r *wasmReader) name() string {
+	return string(r.bytes(r.uleb()))
+}
+
+func (r *wasmReader) limits() {
+	if flags := r.byte(); flags&1 != 0 {
+		r.uleb()
+		r.uleb()
+	} else {
+		r.uleb()
+	}
+}
+
+// constExpr reads a constant expression and returns its value if it is an
+// integer constant.
+func (r *wasmReader) constExpr() (uint64, bool) {
+	var v uint64
+	ok := false
+	switch r.byte() {
+	case 0x41, 0x42: // i32.const, i64.const
+		v, ok = uint64(r.sleb()), true
+		if int64(v) < 0 {
+			v &= 0xffffffff
+		}
+	case 0x23: // global.get
+		r.uleb()
+	default:
+		r.fail(errors.New("unsupported constant expression"))
+	}
+	if r.byte() != 0x0b {
+		r.fail(errors.New("unsupported constant expression"))
+	}
+	return v, ok
+}
+
+func parseWasmSections(data []byte) ([]wasmSection, error) {
+	if len(data) < 8 || !bytes.Equal(data[:4], []byte("\x00asm")) {
+		return nil, errors.New("not a WebAssembly module")
+	}
+	if v := binary.LittleEndian.Uint32(data[4:8]); v != 1 {
+		return nil, fmt.Errorf("unsupported WebAssembly version %d", v)
+	}
+	r := &wasmReader{b: data, pos: 8}
+	var sections []wasmSection
+	for r.pos < len(data) && r.err == nil {
+		s := wasmSection{Start: r.pos}
+		s.ID = r.byte()
+		s.Payload = r.bytes(r.uleb())
+		s.End = r.pos
+		s.Name = wasmSectionNames[s.ID]
+		if s.ID == 0 {
+			pr := &wasmReader{b: s.Payload}
+			s.Name = pr.name()
+			if pr.err != nil {
+				return nil, fmt.Errorf("custom section at %d: %v", s.Start, pr.err)
+			}
+		} else if s.Name == "" {
+			s.Name = fmt.Sprintf("section %d", s.ID)
+		}
+		if r.err != nil {
+			return nil, fmt.Errorf("section at %d: %v", s.Start, r.err)
+		}
+		sections = append(sections, s)
+	}
+	return sections, nil
+}
+
+// wasmImportedFuncs returns the number of functions of the import section,
+// which come first in the function index space.
+func wasmImportedFuncs(payload []byte) (int, error) {
+	r := &wasmReader{b: payload}
+	funcs := 0
+	for n := r.uleb(); n > 0 && r.err == nil; n-- {
+		r.name()
+		r.name()
+		switch kind := r.byte(); kind {
+		case 0: // func
+			r.uleb()
+			funcs++
+		case 1: // table
+			r.byte()
+			r.limits()
+		case 2: // memory
+			r.limits()
+		case 3: // global
+			r.byte()
+			r.byte()
+		case 4: // tag
+			r.byte()
+			r.uleb()
+		default:
+			r.fail(fmt.Errorf("unknown import kind %d", kind))
+		}
+	}
+	return funcs, r.err
+}
+
+// wasmCodeSizes returns the sizes of the function bodies of the code section,
+// including their size.
+func wasmCodeSizes(payload []byte) ([]int64, error) {
+	r := &wasmReader{b: payload}
+	n := r.uleb()
+	var sizes []int64
+	for i := uint64(0); i < n && r.err == nil; i++ {
+		start := r.pos
+		r.bytes(r.uleb())
+		sizes = append(sizes, int64(r.pos-start))
+	}
+	return sizes, r.err
+}
+
+// wasmDataSegments returns the active data segments with a constant address.
+func wasmDataSegments(payload []byte) ([]wasmSegment, error) {
+	r := &wasmReader{b: payload}
+	var segments []wasmSegment
+	for n := r.uleb(); n > 0 && r.err == nil; n-- {
+		var addr uint64
+		active := false
+		switch flags := r.uleb(); flags {
+		case 0:
+			addr, active = r.constExpr()
+		case 1:
+		case 2:
+			r.uleb()
+			addr, active = r.constExpr()
+		default:
+			r.fail(fmt.Errorf("unknown data segment flags %d", flags))
+		}
+		data := r.bytes(r.uleb())
+		if active {
+			segments = append(segments, wasmSegment{Addr: addr, Data: data})
+		}
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i].Addr < segments[j].Addr })
+	return segments, r.err
+}
+
+// wasmFunctionNames returns the function names of the name section by their
+// index.
+func wasmFunctionNames(payload []byte) (map[int]string, error) {
+	r := &wasmReader{b: payload}
+	r.name()
+	names := map[int]string{}
+	for r.pos < len(payload) && r.err == nil {
+		id := r.byte()
+		sub := r.bytes(r.uleb())
+		if id != 1 {
+			continue
+		}
+		sr := &wasmReader{b: sub}
+		for n := sr.uleb(); n > 0 && sr.err == nil; n-- {
+			idx := sr.uleb()
+			names[int(idx)] = sr.name()
+		}
+		if sr.err != nil {
+			return nil, sr.err
+		}
+	}
+	return names, r.err
+}
+
+// wasmMemory is the initial memory of a module, made of its data segments.
+// The bytes between the segments are zero.
+type wasmMemory []wasmSegment
+
+func (m wasmMemory) read(addr, n uint64) []byte {
+	b := make([]byte, n)
+	for _, s := range m {
+		end := s.Addr + uint64(len(s.Data))
+		if end <= addr || s.Addr >= addr+n {
+			continue
+		}
+		from, to := s.Addr, end
+		if from < addr {
+			from = addr
+		}
+		if to > addr+n {
+			to = addr + n
+		}
+		copy(b[from-addr:to-addr], s.Data[from-s.Addr:to-s.Addr])
+	}
+	return b
+}
+
+// fileBytes returns how many bytes of the segments are in [addr, addr+n).
+func (m wasmMemory) fileBytes(addr, n uint64) int64 {
+	var size int64
+	for _, s := range m {
+		from, to := s.Addr, s.Addr+uint64(len(s.Data))
+		if from < addr {
+			from = addr
+		}
+		if to > addr+n {
+			to = addr + n
+		}
+		if from < to {
+			size += int64(to - from)
+		}
+	}
+	return size
+}
+
+func (m wasmMemory) end() uint64 {
+	var end uint64
+	for _, s := range m {
+		if e := s.Addr + uint64(len(s.Data)); e > end {
+			end = e
+		}
+	}
+	return end
+}
+
+// Magic numbers of the pclntab headers of Go 1.18 and Go 1.20 and later.
+var pclntabMagics = [][]byte{
+	{0xf0, 0xff, 0xff, 0xff, 0, 0, 1, 8},
+	{0xf1, 0xff, 0xff, 0xff, 0, 0, 1, 8},
+}
+
+// funcValueOffset is the difference between the index of a Go function in
+// the code section and its PC_F, the upper bits of its PC. The entry offsets
+// of the pclntab of wasm are the PC_Fs.
+const funcValueOffset = 0x1000
+
+// wasmPclntab is the pclntab of a Go module.
+type wasmPclntab struct {
+	Addr uint64
+	Size uint64
+	// Names are the names of the functions by their index in the code
+	// section.
+	Names map[int]string
+}
+
+// findPclntab finds the pclntab of a Go module in its data. The size of the
+// pclntab is taken from the runtime.firstmoduledata that refers to it.
+func findPclntab(m wasmMemory) (*wasmPclntab, error) {
+	for _, s := range m {
+		for _, magic := range pclntabMagics {
+			i := bytes.Index(s.Data, magic)
+			if i < 0 {
+				continue
+			}
+			addr := s.Addr + uint64(i)
+			const ptrSize = 8
+			hdr := m.read(addr, 8+8*ptrSize)
+			nfunc := binary.LittleEndian.Uint64(hdr[8:])
+			funcnameOffset := binary.LittleEndian.Uint64(hdr[8+3*ptrSize:])
+			pclnOffset := binary.LittleEndian.Uint64(hdr[8+7*ptrSize:])
+			size := moduledataPclntabSize(m, addr, funcnameOffset)
+			if size == 0 {
+				size = m.end() - addr
+			}
+			tab := m.read(addr, size)
+			if pclnOffset+(nfunc+1)*8 > size || funcnameOffset > size {
+				return nil, errors.New("pclntab: invalid header")
+			}
+			p := &wasmPclntab{Addr: addr, Size: size, Names: map[int]string{}}
+			// The functab is pairs of the entry offset and the offset of the
+			// _func, which starts with the entry offset and the name offset.
+			for i := uint64(0); i < nfunc; i++ {
+				entry := binary.LittleEndian.Uint32(tab[pclnOffset+8*i:])
+				funcOff := pclnOffset + uint64(binary.LittleEndian.Uint32(tab[pclnOffset+8*i+4:]))
+				if funcOff+8 > size {
+					return nil, errors.New("pclntab: invalid functab")
+				}
+				nameOff := funcnameOffset + uint64(binary.LittleEndian.Uint32(tab[funcOff+4:]))
+				if nameOff >= size {
+					return nil, errors.New("pclntab: invalid function name")
+				}
+				name := tab[nameOff:]
+				if j := bytes.IndexByte(name, 0); j >= 0 {
+					name = name[:j]
+				}
+				p.Names[int(entry)-funcValueOffset] = string(name)
+			}
+			return p, nil
+		}
+	}
+	return nil, nil
+}
+
+// moduledataPclntabSize returns the size of the pclntab at addr, from the
+// moduledata whose first fields are the pcHeader pointer and the funcnametab,
+// cutab, filetab, pctab and pclntable slices. It returns 0 if there is no
+// such moduledata.
+func moduledataPclntabSize(m wasmMemory, addr, funcnameOffset uint64) uint64 {
+	var ptr [16]byte
+	binary.LittleEndian.PutUint64(ptr[:], addr)
+	binary.LittleEndian.PutUint64(ptr[8:], addr+funcnameOffset)
+	for _, s := range m {
+		for i := 0; ; {
+			j := bytes.Index(s.Data[i:], ptr[:])
+			if j < 0 {
+				break
+			}
+			i += j
+			if (s.Addr+uint64(i))%8 == 0 {
+				md := m.read(s.Addr+uint64(i), 8+5*24)
+				// pclntable is the fifth slice.
+				p := binary.LittleEndian.Uint64(md[8+4*24:])
+				n := binary.LittleEndian.Uint64(md[8+4*24+8:])
+				if p > addr && p+n > addr {
+					return p + n - addr
+				}
+			}
+			i++
+		}
+	}
+	return 0
+}
+
+// dwarfUnitSizes returns the size of the .debug_info of every compile unit by
+// its name, which is the package path for Go.
+func dwarfUnitSizes(sections map[string][]byte) (map[string]int64, error) {
+	info := sections[".debug_info"]
+	if info == nil {
+		return nil, nil
+	}
+	d, err := dwarf.New(sections[".debug_abbrev"], sections[".debug_aranges"], sections[".debug_frame"], info, sections[".debug_line"], sections[".debug_pubnames"], sections[".debug_ranges"], sections[".debug_str"])
+	if err != nil {
+		return nil, err
+	}
+	type unit struct {
+		name   string
+		offset dwarf.Offset
+	}
+	var units []unit
+	r := d.Reader()
+	for {
+		e, err := r.Next()
+		if err != nil {
+			return nil, err
+		}
+		if e == nil {
+			break
+		}
+		if e.Tag == dwarf.TagCompileUnit {
+			name, _ := e.Val(dwarf.AttrName).(string)
+			units = append(units, unit{name, e.Offset})
+		}
+		r.SkipChildren()
+	}
+	sizes := map[string]int64{}
+	for i, u := range units {
+		// The offsets are of the unit entries, so the header of a unit
+		// counts for the one before it.
+		end := dwarf.Offset(len(info))
+		if i+1 < len(units) {
+			end = units[i+1].offset
+		}
+		sizes[u.name] += int64(end - u.offset)
+	}
+	return sizes, nil
+}
+
+// PackageOf returns the package path of the Go symbol name, such as
+// "github.com/a/b" for "github.com/a/b.(*T).M". Symbols outside of a package
+// are in "(other)".
+func PackageOf(name string) string {
+	for _, p := range []string{"type:.eq.", "type:.hash.", "type..eq.", "type..hash.", "go:"} {
+		name = strings.TrimPrefix(name, p)
+	}
+	// The type arguments of generic functions contain dots and slashes.
+	if i := strings.IndexByte(name, '['); i >= 0 {
+		name = name[:i]
+	}
+	slash := strings.LastIndexByte(name, '/')
+	dot := strings.IndexByte(name[slash+1:], '.')
+	if dot <= 0 {
+		return "(other)"
+	}
+	// The dots of the last element of the path are escaped, such as
+	// gopkg.in/yaml%2ev3.
+	return strings.ReplaceAll(name[:slash+1+dot], "%2e", ".")
+}
+
+// WasmSizes returns the size report of the wasm module data. The code is
+// attributed to the functions with the names of the name section, or of the
+// Go pclntab if the module is stripped. The data is attributed to the
+// pclntab and the rest. The .debug_info of DWARF is attributed to the
+// compile units.
+func WasmSizes(data []byte) (*SizeReport, error) {
+	sections, err := parseWasmSections(data)
+	if err != nil {
+		return nil, err
+	}
+	rep := &SizeReport{Total: int64(len(data))}
+	symbols := map[[2]string]*SymbolSize{}
+	add := func(name, pkg, kind string, size int64) {
+		if size == 0 {
+			return
+		}
+		k := [2]string{name, kind}
+		if s, ok := symbols[k]; ok {
+			s.Size += size
+			return
+		}
+		symbols[k] = &SymbolSize{Name: name, Package: pkg, Kind: kind, Size: size}
+	}
+	add("(header)", SizePackageWasm, SizeKindOther, 8)
+
+	imported := 0
+	var names map[int]string
+	var segments wasmMemory
+	debug := map[string][]byte{}
+	for _, s := range sections {
+		var err error
+		switch {
+		case s.ID == 2:
+			imported, err = wasmImportedFuncs(s.Payload)
+		case s.ID == 11:
+			segments, err = wasmDataSegments(s.Payload)
+		case s.ID == 0 && s.Name == "name":
+			names, err = wasmFunctionNames(s.Payload)
+		case s.ID == 0 && strings.HasPrefix(s.Name, ".debug_"):
+			pr := &wasmReader{b: s.Payload}
+			pr.name()
+			debug[s.Name] = s.Payload[pr.pos:]
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s section: %v", s.Name, err)
+		}
+	}
+	pclntab, err := findPclntab(segments)
+	if err != nil {
+		return nil, err
+	}
+	units, err := dwarfUnitSizes(debug)
+	if err != nil {
+		return nil, fmt.Errorf("DWARF: %v", err)
+	}
+
+	sectionSizes := map[string]int64{}
+	var sectionOrder []string
+	for _, s := range sections {
+		size := int64(s.End - s.Start)
+		if _, ok := sectionSizes[s.Name]; !ok {
+			sectionOrder = append(sectionOrder, s.Name)
+		}
+		sectionSizes[s.Name] += size
+
+		var attributed int64
+		switch {
+		case s.ID == 10:
+			sizes, err := wasmCodeSizes(s.Payload)
+			if err != nil {
+				return nil, fmt.Errorf("code section: %v", err)
+			}
+			for i, n := range sizes {
+				// The name section of Go mangles the names, such as
+				// syscall_js.Value.Call for syscall/js.Value.Call.
+				var name string
+				ok := false
+				if pclntab != nil {
+					name, ok = pclntab.Names[i]
+				}
+				if !ok {
+					name, ok = names[imported+i]
+				}
+				if !ok {
+					name = fmt.Sprintf("func[%d]", imported+i)
+				}
+				add(name, PackageOf(name), SizeKindCode, n)
+				attributed += n
+			}
+		case s.ID == 11:
+			var p int64
+			if pclntab != nil {
+				p = segments.fileBytes(pclntab.Addr, pclntab.Size)
+				add("runtime.pclntab", SizePackagePclntab, SizeKindData, p)
+			}
+			var all int64
+			for _, seg := range segments {
+				all += int64(len(seg.Data))
+			}
+			add("(data)", SizePackageData, SizeKindData, all-p)
+			attributed = all
+		case s.ID == 0 && s.Name == ".debug_info":
+			for name, n := range units {
+				add(name+" (debug info)", name, SizeKindDebug, n)
+				attributed += n
+			}
+		}
+		add("("+s.Name+" section)", SizePackageWasm, SizeKindOther, size-attributed)
+	}
+
+	for _, name := range sectionOrder {
+		rep.Sections = append(rep.Sections, SectionSize{Name: name, Size: sectionSizes[name]})
+	}
+	sort.SliceStable(rep.Sections, func(i, j int) bool { return rep.Sections[i].Size > rep.Sections[j].Size })
+
+	packages := map[string]*PackageSize{}
+	for _, s := range symbols {
+		rep.Symbols = append(rep.Symbols, *s)
+		p, ok := packages[s.Package]
+		if !ok {
+			p = &PackageSize{Name: s.Package}
+			packages[s.Package] = p
+		}
+		p.Size += s.Size
+		switch s.Kind {
+		case SizeKindCode:
+			p.Code += s.Size
+		case SizeKindData:
+			p.Data += s.Size
+		case SizeKindDebug:
+			p.Debug += s.Size
+		default:
+			p.Other += s.Size
+		}
+	}
+	sort.Slice(rep.Symbols, func(i, j int) bool {
+		a, b := rep.Symbols[i], rep.Symbols[j]
+		if a.Size != b.Size {
+			return a.Size > b.Size
+		}
+		return a.Name < b.Name
+	})
+	for _, p := range packages {
+		rep.Packages = append(rep.Packages, *p)
+	}
+	sort.Slice(rep.Packages, func(i, j int) bool {
+		a, b := rep.Packages[i], rep.Packages[j]
+		if a.Size != b.Size {
+			return a.Size > b.Size
+		}
+		return a.Name < b.Name
+	})
+	return rep, nil
+}
+
+// SizeDelta is the change of the size of a section, a package or a symbol
+// between two builds.
+type SizeDelta struct {
+	Name  string `json:"name"`
+	Old   int64  `json:"old"`
+	New   int64  `json:"new"`
+	Delta int64  `json:"delta"`
+}
+
+// SizeDiff compares the size reports of two builds. The deltas are sorted by
+// their absolute value, and the unchanged entries are left out.
+type SizeDiff struct {
+	OldTotal int64       `json:"old_total"`
+	NewTotal int64       `json:"new_total"`
+	Delta    int64       `json:"delta"`
+	Sections []SizeDelta `json:"sections"`
+	Packages []SizeDelta `json:"packages"`
+	Symbols  []SizeDelta `json:"symbols"`
+}
+
+// DiffSizes compares the size report old with new.
+func DiffSizes(old, new *SizeReport) *SizeDiff {
+	d := &SizeDiff{OldTotal: old.Total, NewTotal: new.Total, Delta: new.Total - old.Total}
+
+	sections := func(r *SizeReport) map[string]int64 {
+		m := map[string]int64{}
+		for _, s := range r.Sections {
+			m[s.Name] += s.Size
+		}
+		return m
+	}
+	d.Sections = sizeDeltas(sections(old), sections(new))
+
+	packages := func(r *SizeReport) map[string]int64 {
+		m := map[string]int64{}
+		for _, p := range r.Packages {
+			m[p.Name] += p.Size
+		}
+		return m
+	}
+	d.Packages = sizeDeltas(packages(old), packages(new))
+
+	symbols := func(r *SizeReport) map[string]int64 {
+		m := map[string]int64{}
+		for _, s := range r.Symbols {
+			m[s.Name] += s.Size
+		}
+		return m
+	}
+	d.Symbols = sizeDeltas(symbols(old), symbols(new))
+	return d
+}
+
+func sizeDeltas(old, new map[string]int64) []SizeDelta {
+	var deltas []SizeDelta
+	for name, n := range new {
+		if o := old[name]; o != n {
+			deltas = append(deltas, SizeDelta{Name: name, Old: o, New: n, Delta: n - o})
+		}
+	}
+	for name, o := range old {
+		if _, ok := new[name]; !ok {
+			deltas = append(deltas, SizeDelta{Name: name, Old: o, Delta: -o})
+		}
+	}
+	abs := func(n int64) int64 {
+		if n < 0 {
+			return -n
+		}
+		return n
+	}
+	sort.Slice(deltas, func(i, j int) bool {
+		a, b := deltas[i], deltas[j]
+		if abs(a.Delta) != abs(b.Delta) {
+			return abs(a.Delta) > abs(b.Delta)
+		}
+		return a.Name < b.Name
+	})
+	return deltas
+}
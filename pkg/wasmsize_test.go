@@ -0,0 +1,191 @@
+package pkg
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func uleb(n uint64) []byte {
+	var b []byte
+	for {
+		c := byte(n & 0x7f)
+		n >>= 7
+		if n != 0 {
+			b = append(b, c|0x80)
+			continue
+		}
+		return append(b, c)
+	}
+}
+
+func wasmVec(n int, items ...[]byte) []byte {
+	b := uleb(uint64(n))
+	for _, i := range items {
+		b = append(b, i...)
+	}
+	return b
+}
+
+func wasmName(s string) []byte {
+	return append(uleb(uint64(len(s))), s...)
+}
+
+func wasmSectionBytes(id byte, payload []byte) []byte {
+	return append(append([]byte{id}, uleb(uint64(len(payload)))...), payload...)
+}
+
+func wasmDataSegment(addr uint64, data []byte) []byte {
+	b := []byte{0, 0x41}
+	b = append(b, uleb(addr)...)
+	b = append(b, 0x0b)
+	b = append(b, uleb(uint64(len(data)))...)
+	return append(b, data...)
+}
+
+// testPclntab returns a pclntab at addr with the functions main.main and
+// fmt.Println, at the indexes 0 and 1 of the code section, and a moduledata
+// that refers to it.
+func testPclntab(addr uint64) ([]byte, []byte) {
+	tab := make([]byte, 136)
+	copy(tab, []byte{0xf1, 0xff, 0xff, 0xff, 0, 0, 1, 8})
+	le := binary.LittleEndian
+	le.PutUint64(tab[8:], 2)   // nfunc
+	le.PutUint64(tab[32:], 72) // funcnameOffset
+	le.PutUint64(tab[64:], 96) // pclnOffset
+	copy(tab[72:], "main.main\x00fmt.Println\x00")
+	// functab
+	le.PutUint32(tab[96:], funcValueOffset)
+	le.PutUint32(tab[100:], 24)
+	le.PutUint32(tab[104:], funcValueOffset+1)
+	le.PutUint32(tab[108:], 32)
+	le.PutUint32(tab[112:], funcValueOffset+2)
+	// _func
+	le.PutUint32(tab[120:], funcValueOffset)
+	le.PutUint32(tab[124:], 0)
+	le.PutUint32(tab[128:], funcValueOffset+1)
+	le.PutUint32(tab[132:], 10)
+
+	md := make([]byte, 128)
+	le.PutUint64(md[0:], addr)
+	le.PutUint64(md[8:], addr+72)
+	le.PutUint64(md[8+4*24:], addr+96)
+	le.PutUint64(md[8+4*24+8:], 40)
+	return tab, md
+}
+
+func testModule(names bool, data bool) []byte {
+	m := []byte("\x00asm\x01\x00\x00\x00")
+	imp := append(append(wasmName("go"), wasmName("debug")...), 0, 0)
+	m = append(m, wasmSectionBytes(2, wasmVec(1, imp))...)
+	body1 := append(uleb(100), make([]byte, 100)...)
+	body2 := append(uleb(20), make([]byte, 20)...)
+	m = append(m, wasmSectionBytes(10, wasmVec(2, body1, body2))...)
+	if data {
+		tab, md := testPclntab(0x1000)
+		m = append(m, wasmSectionBytes(11, wasmVec(3,
+			wasmDataSegment(0x1000, tab),
+			wasmDataSegment(0x2000, md),
+			wasmDataSegment(0x3000, make([]byte, 50)),
+		))...)
+	}
+	if names {
+		fn := wasmVec(2, append(uleb(1), wasmName("main_main")...), append(uleb(2), wasmName("fmt_Println")...))
+		sub := append([]byte{1}, append(uleb(uint64(len(fn))), fn...)...)
+		m = append(m, wasmSectionBytes(0, append(wasmName("name"), sub...))...)
+	}
+	return m
+}
+
+func symbolSize(rep *SizeReport, name string) int64 {
+	for _, s := range rep.Symbols {
+		if s.Name == name {
+			return s.Size
+		}
+	}
+	return -1
+}
+
+func TestWasmSizes(t *testing.T) {
+	data := testModule(true, true)
+	rep, err := WasmSizes(data)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(len(data)), rep.Total)
+
+	var sum int64
+	for _, s := range rep.Symbols {
+		sum += s.Size
+	}
+	assert.Equal(t, rep.Total, sum)
+
+	// The pclntab names win over the mangled ones of the name section.
+	assert.Equal(t, int64(101), symbolSize(rep, "main.main"))
+	assert.Equal(t, int64(21), symbolSize(rep, "fmt.Println"))
+	assert.Equal(t, int64(136), symbolSize(rep, "runtime.pclntab"))
+	assert.Equal(t, int64(128+50), symbolSize(rep, "(data)"))
+	assert.Equal(t, "data", rep.Sections[0].Name)
+
+	pkgs := map[string]PackageSize{}
+	for _, p := range rep.Packages {
+		pkgs[p.Name] = p
+	}
+	assert.Equal(t, int64(101), pkgs["main"].Code)
+	assert.Equal(t, int64(21), pkgs["fmt"].Size)
+	assert.Equal(t, int64(136), pkgs[SizePackagePclntab].Data)
+
+	// Without a pclntab, the names of the name section are used.
+	rep, err = WasmSizes(testModule(true, false))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(101), symbolSize(rep, "main_main"))
+
+	rep, err = WasmSizes(testModule(false, false))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(101), symbolSize(rep, "func[1]"))
+
+	_, err = WasmSizes([]byte("not wasm"))
+	assert.NotNil(t, err)
+	_, err = WasmSizes(data[:len(data)-3])
+	assert.NotNil(t, err)
+}
+
+func TestPackageOf(t *testing.T) {
+	cases := map[string]string{
+		"main.main":                            "main",
+		"runtime.(*mheap).alloc":               "runtime",
+		"github.com/a/b.(*T).M":                "github.com/a/b",
+		"github.com/a/b.F[go.shape.*uint8]":    "github.com/a/b",
+		"syscall/js.Value.Call":                "syscall/js",
+		"type:.eq.github.com/a/b.T":            "github.com/a/b",
+		"gopkg.in/yaml%2ev3.Unmarshal":         "gopkg.in/yaml.v3",
+		"wasm_pc_f_loop":                       "(other)",
+		"vendor/golang.org/x/net/idna.ToASCII": "vendor/golang.org/x/net/idna",
+	}
+	for in, want := range cases {
+		assert.Equal(t, want, PackageOf(in), in)
+	}
+}
+
+func TestDiffSizes(t *testing.T) {
+	old := &SizeReport{
+		Total:    100,
+		Sections: []SectionSize{{"code", 80}, {"data", 20}},
+		Packages: []PackageSize{{Name: "main", Size: 30}, {Name: "fmt", Size: 50}},
+		Symbols:  []SymbolSize{{Name: "main.main", Size: 30}, {Name: "fmt.Println", Size: 50}},
+	}
+	new := &SizeReport{
+		Total:    130,
+		Sections: []SectionSize{{"code", 110}, {"data", 20}},
+		Packages: []PackageSize{{Name: "main", Size: 35}, {Name: "strings", Size: 75}},
+		Symbols:  []SymbolSize{{Name: "main.main", Size: 35}, {Name: "strings.Cut", Size: 75}},
+	}
+	d := DiffSizes(old, new)
+	assert.Equal(t, int64(30), d.Delta)
+	assert.Equal(t, []SizeDelta{{"code", 80, 110, 30}}, d.Sections)
+	assert.Equal(t, []SizeDelta{
+		{"strings", 0, 75, 75},
+		{"fmt", 50, 0, -50},
+		{"main", 30, 35, 5},
+	}, d.Packages)
+	assert.Equal(t, "strings.Cut", d.Symbols[0].Name)
+}
@@ -1,5 +1,5 @@
 module.exports = {
-  content: [],
+  content: ["./**/*.go", "./tmp/tailwind-classes.txt"],
   theme: {
     extend: {},
   },